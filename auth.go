@@ -0,0 +1,356 @@
+package rerpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 认证使用的元数据键
+const (
+	MetadataAuthorization = "authorization"  // Bearer 令牌，格式为 "Bearer <token>"
+	MetadataAuthKeyID     = "auth-key-id"    // HMAC 签名使用的密钥 ID
+	MetadataAuthTimestamp = "auth-timestamp" // HMAC 签名时间（Unix 秒）
+	MetadataAuthNonce     = "auth-nonce"     // HMAC 签名随机数，用于防重放
+	MetadataAuthSignature = "auth-signature" // HMAC-SHA256 签名（小写十六进制）
+)
+
+// 默认值
+const (
+	defaultReplayWindow = 5 * time.Minute  // HMAC 签名时间允许的偏差
+	tokenExpiryLeeway   = 10 * time.Second // 令牌到期前提前刷新的时间
+)
+
+// 认证失败的原因，作为 Unauthenticated 错误的 data 返回给客户端
+var (
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrInvalidSignature   = errors.New("invalid signature")
+	ErrRequestExpired     = errors.New("request timestamp outside replay window")
+	ErrReplayedRequest    = errors.New("replayed request")
+)
+
+// Principal 认证通过的调用方身份
+// 服务方法和服务端拦截器通过 PrincipalFromContext 获取，用于授权
+type Principal struct {
+	ID         string   // 调用方标识，如用户 ID、服务名或 HMAC 密钥 ID
+	Attributes Metadata // 认证器附加的属性，如租户、角色
+}
+
+// AuthInfo 认证器可以使用的请求信息
+type AuthInfo struct {
+	Method   string          // 完整方法名（如 "Arith.Add"）
+	Params   json.RawMessage // 请求中的原始参数
+	Metadata Metadata        // 请求元数据
+	Peer     *Peer           // 客户端信息，可以用于基于 mTLS 证书的认证
+}
+
+// Authenticator 服务端认证器
+// 在拦截器链和服务方法之前为每个请求（包括通知）调用，实现必须是并发安全的
+type Authenticator interface {
+	// Authenticate 验证请求并返回调用方身份
+	// 返回错误时请求被拒绝：*Error 原样返回给客户端，其他错误转换为 Unauthenticated 错误，错误消息作为 data
+	// 返回 nil, nil 时请求以匿名身份继续执行（服务方法中 PrincipalFromContext 返回 false）
+	Authenticate(ctx context.Context, info *AuthInfo) (*Principal, error)
+}
+
+// AuthenticatorFunc 将函数适配为 Authenticator
+type AuthenticatorFunc func(ctx context.Context, info *AuthInfo) (*Principal, error)
+
+// Authenticate 调用 f
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, info *AuthInfo) (*Principal, error) {
+	return f(ctx, info)
+}
+
+// principalKey context 中保存 *Principal 的键
+type principalKey struct{}
+
+// PrincipalFromContext 获取认证通过的调用方身份
+// 服务端没有配置 Authenticator 或认证器返回匿名身份时返回 false
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// contextWithPrincipal 将调用方身份保存到 context
+func contextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// authenticate 使用服务端配置的认证器验证请求
+// 认证通过时返回携带调用方身份的 ctx
+func (s *Server) authenticate(ctx context.Context, req *Request) (context.Context, *Error) {
+	info := &AuthInfo{
+		Method:   req.Method,
+		Params:   req.Params,
+		Metadata: req.Metadata,
+	}
+	info.Peer, _ = PeerFromContext(ctx)
+
+	principal, err := s.authenticator.Authenticate(ctx, info)
+	if err != nil {
+		var rpcErr *Error
+		if errors.As(err, &rpcErr) && rpcErr != nil {
+			return ctx, rpcErr
+		}
+		return ctx, NewUnauthenticatedError(err.Error())
+	}
+	if principal == nil {
+		return ctx, nil
+	}
+	return contextWithPrincipal(ctx, principal), nil
+}
+
+// NewBearerAuthenticator 创建 Bearer 令牌认证器
+// 从请求元数据 authorization 中读取 "Bearer <token>"，交给 validate 验证并返回调用方身份
+// validate 返回的错误会作为 Unauthenticated 错误的 data 返回给客户端，不应包含敏感信息
+func NewBearerAuthenticator(validate func(ctx context.Context, token string) (*Principal, error)) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, info *AuthInfo) (*Principal, error) {
+		token, ok := bearerToken(info.Metadata[MetadataAuthorization])
+		if !ok {
+			return nil, ErrMissingCredentials
+		}
+		principal, err := validate(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if principal == nil {
+			return nil, ErrInvalidToken
+		}
+		return principal, nil
+	})
+}
+
+// bearerToken 解析 "Bearer <token>"，方案名不区分大小写
+func bearerToken(value string) (string, bool) {
+	const prefix = "bearer "
+	if len(value) <= len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(value[len(prefix):])
+	return token, token != ""
+}
+
+// HMACAuthenticator HMAC 签名认证器
+// 客户端（NewHMACCredentials）对 方法名、时间戳、随机数和参数 计算 HMAC-SHA256 签名，
+// 服务端验证签名，并拒绝时间戳超出重放窗口或随机数在窗口内重复使用的请求
+// 认证通过时调用方身份的 ID 为密钥 ID
+type HMACAuthenticator struct {
+	keys   func(keyID string) ([]byte, bool) // 按密钥 ID 查找密钥
+	window time.Duration                     // 时间戳允许的偏差
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // 窗口内已使用的随机数 -> 过期时间
+	lastSweep time.Time            // 上次清理过期随机数的时间
+}
+
+// NewHMACAuthenticator 创建 HMAC 签名认证器
+// keys: 按密钥 ID 查找密钥，未知的密钥 ID 返回 false
+// window: 请求时间戳与服务端时间允许的最大偏差，<= 0 时使用默认值 5 分钟
+// 已使用的随机数保存在内存中，多个服务端实例之间不共享
+func NewHMACAuthenticator(keys func(keyID string) ([]byte, bool), window time.Duration) *HMACAuthenticator {
+	if window <= 0 {
+		window = defaultReplayWindow
+	}
+	return &HMACAuthenticator{
+		keys:   keys,
+		window: window,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Authenticate 验证请求的 HMAC 签名
+func (a *HMACAuthenticator) Authenticate(ctx context.Context, info *AuthInfo) (*Principal, error) {
+	md := info.Metadata
+	keyID, ts, nonce, sig := md[MetadataAuthKeyID], md[MetadataAuthTimestamp], md[MetadataAuthNonce], md[MetadataAuthSignature]
+	if keyID == "" || ts == "" || nonce == "" || sig == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	key, ok := a.keys(keyID)
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+	expected := signRequest(key, info.Method, ts, nonce, info.Params)
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, expected) {
+		return nil, ErrInvalidSignature
+	}
+
+	// 签名验证通过后再检查时间戳和随机数，避免伪造的请求占用随机数
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	signedAt := time.Unix(unix, 0)
+	now := time.Now()
+	if signedAt.Before(now.Add(-a.window)) || signedAt.After(now.Add(a.window)) {
+		return nil, ErrRequestExpired
+	}
+	if !a.markNonce(keyID+":"+nonce, signedAt.Add(a.window), now) {
+		return nil, ErrReplayedRequest
+	}
+
+	return &Principal{ID: keyID}, nil
+}
+
+// markNonce 记录随机数，窗口内重复使用时返回 false
+// 随机数保留到签名时间超出窗口为止，之后同样的请求会因时间戳过期被拒绝
+func (a *HMACAuthenticator) markNonce(nonce string, expiry, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if now.Sub(a.lastSweep) >= a.window {
+		for k, exp := range a.seen {
+			if now.After(exp) {
+				delete(a.seen, k)
+			}
+		}
+		a.lastSweep = now
+	}
+
+	if exp, ok := a.seen[nonce]; ok && !now.After(exp) {
+		return false
+	}
+	a.seen[nonce] = expiry
+	return true
+}
+
+// signRequest 计算请求签名：HMAC-SHA256(key, method + "\n" + timestamp + "\n" + nonce + "\n" + params)
+func signRequest(key []byte, method, timestamp, nonce string, params []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(method))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(nonce))
+	mac.Write([]byte{'\n'})
+	mac.Write(params)
+	return mac.Sum(nil)
+}
+
+// Credentials 客户端凭据，为每个请求（包括重试和通知）生成认证元数据
+// 返回的元数据与 WithMetadata 设置的元数据合并，同名键以凭据为准；实现必须是并发安全的
+type Credentials interface {
+	RequestMetadata(ctx context.Context, method string, params json.RawMessage) (Metadata, error)
+}
+
+// Token 访问令牌
+type Token struct {
+	AccessToken string    // 令牌
+	Expiry      time.Time // 过期时间，零值表示不过期
+}
+
+// valid 判断令牌是否可用，在过期前 tokenExpiryLeeway 即视为失效
+func (t *Token) valid() bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(tokenExpiryLeeway).Before(t.Expiry)
+}
+
+// TokenSource 提供访问令牌，如从认证服务获取或刷新令牌
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenSourceFunc 将函数适配为 TokenSource
+type TokenSourceFunc func(ctx context.Context) (*Token, error)
+
+// Token 调用 f
+func (f TokenSourceFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// StaticToken 返回总是提供同一个不过期令牌的 TokenSource
+func StaticToken(token string) TokenSource {
+	t := &Token{AccessToken: token}
+	return TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		return t, nil
+	})
+}
+
+// bearerCredentials 以 Bearer 令牌认证的客户端凭据
+type bearerCredentials struct {
+	source TokenSource
+	mu     sync.Mutex // 保护 token，同时保证过期时只有一个调用去刷新
+	token  *Token
+}
+
+// NewBearerCredentials 创建 Bearer 令牌凭据，令牌放在请求元数据 authorization 中
+// 令牌会被缓存，在过期前 10 秒从 source 重新获取
+func NewBearerCredentials(source TokenSource) Credentials {
+	return &bearerCredentials{source: source}
+}
+
+// RequestMetadata 返回携带令牌的元数据，令牌即将过期时刷新
+func (c *bearerCredentials) RequestMetadata(ctx context.Context, method string, params json.RawMessage) (Metadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.token.valid() {
+		token, err := c.source.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh token: %w", err)
+		}
+		if token == nil || token.AccessToken == "" {
+			return nil, errors.New("failed to refresh token: empty token")
+		}
+		c.token = token
+	}
+	return Metadata{MetadataAuthorization: "Bearer " + c.token.AccessToken}, nil
+}
+
+// hmacCredentials 以 HMAC 签名认证的客户端凭据
+type hmacCredentials struct {
+	keyID string
+	key   []byte
+}
+
+// NewHMACCredentials 创建 HMAC 签名凭据，与服务端的 HMACAuthenticator 配合使用
+// 每个请求使用当前时间和新的随机数签名，重试的请求会重新签名
+func NewHMACCredentials(keyID string, key []byte) Credentials {
+	return &hmacCredentials{keyID: keyID, key: key}
+}
+
+// RequestMetadata 返回请求的签名元数据
+func (c *hmacCredentials) RequestMetadata(ctx context.Context, method string, params json.RawMessage) (Metadata, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	nonce := hex.EncodeToString(buf[:])
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	return Metadata{
+		MetadataAuthKeyID:     c.keyID,
+		MetadataAuthTimestamp: ts,
+		MetadataAuthNonce:     nonce,
+		MetadataAuthSignature: hex.EncodeToString(signRequest(c.key, method, ts, nonce, params)),
+	}, nil
+}
+
+// setRequestMetadata 设置请求元数据：ctx 中的元数据加上客户端凭据生成的认证元数据
+// 必须在参数序列化之后调用，HMAC 签名覆盖参数
+func (c *Client) setRequestMetadata(ctx context.Context, req *Request) error {
+	req.Metadata = outgoingMetadata(ctx)
+	if c.credentials == nil {
+		return nil
+	}
+
+	md, err := c.credentials.RequestMetadata(ctx, req.Method, req.Params)
+	if err != nil {
+		return fmt.Errorf("failed to get credentials: %w", err)
+	}
+	req.Metadata = req.Metadata.merge(md)
+	return nil
+}
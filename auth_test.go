@@ -0,0 +1,294 @@
+package rerpc
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// AuthService 返回认证通过的调用方身份
+type AuthService struct{}
+
+func (s *AuthService) Whoami(ctx context.Context, args *EchoArgs, reply *EchoReply) error {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		reply.Message = "anonymous"
+		return nil
+	}
+	reply.Message = p.ID + "/" + p.Attributes["tenant"]
+	return nil
+}
+
+// startAuthServer 启动配置了认证器的服务器，返回 TCP 地址和 HTTP 地址
+func startAuthServer(t *testing.T, auth Authenticator) (string, string) {
+	t.Helper()
+
+	server := NewServerWithConfig(ServerConfig{Workers: 4, Authenticator: auth})
+	if err := server.Register(&AuthService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	ts := httptest.NewServer(server.HTTPHandler())
+	t.Cleanup(ts.Close)
+
+	return listener.Addr().String(), ts.URL
+}
+
+// TestBearerAuth 测试 Bearer 令牌认证和令牌刷新
+func TestBearerAuth(t *testing.T) {
+	auth := NewBearerAuthenticator(func(ctx context.Context, token string) (*Principal, error) {
+		if token != "good-1" && token != "good-2" {
+			return nil, ErrInvalidToken
+		}
+		return &Principal{ID: "alice", Attributes: Metadata{"tenant": token}}, nil
+	})
+	addr, url := startAuthServer(t, auth)
+
+	transports := []struct {
+		name   string
+		config ClientConfig
+	}{
+		{"Pool", ClientConfig{Address: addr}},
+		{"Multiplex", ClientConfig{Address: addr, Multiplex: true}},
+		{"HTTP", ClientConfig{Address: url}},
+	}
+
+	for _, tt := range transports {
+		t.Run(tt.name, func(t *testing.T) {
+			// 第一个令牌已经在刷新提前量之内，第二次获取返回新令牌
+			var mu sync.Mutex
+			fetched := 0
+			source := TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				fetched++
+				if fetched == 1 {
+					return &Token{AccessToken: "good-1", Expiry: time.Now().Add(time.Second)}, nil
+				}
+				return &Token{AccessToken: "good-2", Expiry: time.Now().Add(time.Hour)}, nil
+			})
+
+			config := tt.config
+			config.Credentials = NewBearerCredentials(source)
+			client, err := NewClient(config)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			for _, want := range []string{"alice/good-1", "alice/good-2", "alice/good-2"} {
+				var reply EchoReply
+				if err := client.Call(ctx, "AuthService.Whoami", &EchoArgs{}, &reply); err != nil {
+					t.Fatalf("Call failed: %v", err)
+				}
+				if reply.Message != want {
+					t.Errorf("Expected %q, got %q", want, reply.Message)
+				}
+			}
+			if fetched != 2 {
+				t.Errorf("Expected 2 token fetches, got %d", fetched)
+			}
+
+			// 没有凭据和无效令牌都返回 Unauthenticated
+			anon, err := NewClient(tt.config)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			defer anon.Close()
+
+			var reply EchoReply
+			err = anon.Call(ctx, "AuthService.Whoami", &EchoArgs{}, &reply)
+			assertUnauthenticated(t, err, ErrMissingCredentials)
+
+			err = anon.Call(WithMetadata(ctx, Metadata{MetadataAuthorization: "Bearer bad"}), "AuthService.Whoami", &EchoArgs{}, &reply)
+			assertUnauthenticated(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+// assertUnauthenticated 检查错误为 Unauthenticated，原因为 reason
+func assertUnauthenticated(t *testing.T, err error, reason error) {
+	t.Helper()
+
+	rpcErr, ok := AsError(err)
+	if !ok {
+		t.Fatalf("Expected JSON-RPC error, got %v", err)
+	}
+	if rpcErr.Code != ErrCodeUnauthenticated {
+		t.Errorf("Expected code %d, got %d", ErrCodeUnauthenticated, rpcErr.Code)
+	}
+	if rpcErr.Data != reason.Error() {
+		t.Errorf("Expected data %q, got %v", reason.Error(), rpcErr.Data)
+	}
+}
+
+// TestBearerCredentialsError 测试令牌获取失败时调用不会发出
+func TestBearerCredentialsError(t *testing.T) {
+	errRefresh := errors.New("token endpoint unavailable")
+	creds := NewBearerCredentials(TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		return nil, errRefresh
+	}))
+	addr, _ := startAuthServer(t, nil)
+
+	client, err := NewClient(ClientConfig{Address: addr, Credentials: creds})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	var reply EchoReply
+	err = client.Call(context.Background(), "AuthService.Whoami", &EchoArgs{}, &reply)
+	if !errors.Is(err, errRefresh) {
+		t.Errorf("Expected refresh error, got %v", err)
+	}
+}
+
+// TestHMACAuth 测试 HMAC 签名认证
+func TestHMACAuth(t *testing.T) {
+	keys := map[string][]byte{"svc-a": []byte("secret-a")}
+	auth := NewHMACAuthenticator(func(keyID string) ([]byte, bool) {
+		key, ok := keys[keyID]
+		return key, ok
+	}, time.Minute)
+	addr, url := startAuthServer(t, auth)
+
+	for _, address := range []string{addr, url} {
+		client, err := NewClient(ClientConfig{Address: address, Credentials: NewHMACCredentials("svc-a", keys["svc-a"])})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		defer client.Close()
+
+		var reply EchoReply
+		if err := client.Call(context.Background(), "AuthService.Whoami", &EchoArgs{Message: "hi"}, &reply); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if reply.Message != "svc-a/" {
+			t.Errorf("Expected principal svc-a, got %q", reply.Message)
+		}
+
+		// 错误的密钥
+		bad, err := NewClient(ClientConfig{Address: address, Credentials: NewHMACCredentials("svc-a", []byte("wrong"))})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		defer bad.Close()
+
+		err = bad.Call(context.Background(), "AuthService.Whoami", &EchoArgs{}, &reply)
+		assertUnauthenticated(t, err, ErrInvalidSignature)
+	}
+}
+
+// TestHMACAuthenticator 测试签名覆盖的内容、重放和过期检查
+func TestHMACAuthenticator(t *testing.T) {
+	key := []byte("secret")
+	auth := NewHMACAuthenticator(func(keyID string) ([]byte, bool) {
+		return key, keyID == "k"
+	}, time.Minute)
+	creds := NewHMACCredentials("k", key)
+
+	ctx := context.Background()
+	params := json.RawMessage(`{"a":1}`)
+	md, err := creds.RequestMetadata(ctx, "Arith.Add", params)
+	if err != nil {
+		t.Fatalf("RequestMetadata failed: %v", err)
+	}
+
+	info := &AuthInfo{Method: "Arith.Add", Params: params, Metadata: md}
+	p, err := auth.Authenticate(ctx, info)
+	if err != nil || p.ID != "k" {
+		t.Fatalf("Expected principal k, got %v, %v", p, err)
+	}
+
+	// 同一个请求再次发送
+	if _, err := auth.Authenticate(ctx, info); !errors.Is(err, ErrReplayedRequest) {
+		t.Errorf("Expected ErrReplayedRequest, got %v", err)
+	}
+
+	// 篡改方法名或参数
+	md, _ = creds.RequestMetadata(ctx, "Arith.Add", params)
+	if _, err := auth.Authenticate(ctx, &AuthInfo{Method: "Arith.Sub", Params: params, Metadata: md}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for method, got %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, &AuthInfo{Method: "Arith.Add", Params: json.RawMessage(`{"a":2}`), Metadata: md}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for params, got %v", err)
+	}
+
+	// 超出重放窗口的签名
+	ts := time.Now().Add(-2 * time.Minute).Unix()
+	old := Metadata{
+		MetadataAuthKeyID:     "k",
+		MetadataAuthTimestamp: formatUnix(ts),
+		MetadataAuthNonce:     "n1",
+		MetadataAuthSignature: hexSign(key, "Arith.Add", formatUnix(ts), "n1", params),
+	}
+	if _, err := auth.Authenticate(ctx, &AuthInfo{Method: "Arith.Add", Params: params, Metadata: old}); !errors.Is(err, ErrRequestExpired) {
+		t.Errorf("Expected ErrRequestExpired, got %v", err)
+	}
+
+	// 缺少签名
+	if _, err := auth.Authenticate(ctx, &AuthInfo{Method: "Arith.Add"}); !errors.Is(err, ErrMissingCredentials) {
+		t.Errorf("Expected ErrMissingCredentials, got %v", err)
+	}
+}
+
+// TestAuthenticatorError 测试认证器返回的 *Error 原样返回，匿名请求可以通过
+func TestAuthenticatorError(t *testing.T) {
+	forbidden := NewError(-32003, "Forbidden", nil)
+	auth := AuthenticatorFunc(func(ctx context.Context, info *AuthInfo) (*Principal, error) {
+		if info.Method == "AuthService.Whoami" && info.Metadata["role"] == "guest" {
+			return nil, forbidden
+		}
+		if info.Peer == nil {
+			return nil, errors.New("no peer")
+		}
+		return nil, nil
+	})
+	addr, _ := startAuthServer(t, auth)
+
+	client, err := NewClient(ClientConfig{Address: addr})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	var reply EchoReply
+	if err := client.Call(context.Background(), "AuthService.Whoami", &EchoArgs{}, &reply); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if reply.Message != "anonymous" {
+		t.Errorf("Expected anonymous, got %q", reply.Message)
+	}
+
+	err = client.Call(WithMetadata(context.Background(), Metadata{"role": "guest"}), "AuthService.Whoami", &EchoArgs{}, &reply)
+	if rpcErr, ok := AsError(err); !ok || rpcErr.Code != -32003 {
+		t.Errorf("Expected Forbidden error, got %v", err)
+	}
+}
+
+// formatUnix 格式化签名时间戳
+func formatUnix(ts int64) string {
+	return strconv.FormatInt(ts, 10)
+}
+
+// hexSign 计算十六进制签名
+func hexSign(key []byte, method, ts, nonce string, params []byte) string {
+	return hex.EncodeToString(signRequest(key, method, ts, nonce, params))
+}
@@ -0,0 +1,186 @@
+package rerpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// CancelRequestMethod 取消请求的通知方法名（与 LSP 的 $/cancelRequest 一致）
+// 客户端在调用的 context 取消或超时时自动发送，服务端取消同一连接上对应请求的 ctx
+// 以 $/ 开头的方法名保留给协议使用，不会被路由到服务方法
+const CancelRequestMethod = "$/cancelRequest"
+
+// cancelWriteTimeout 发送取消通知的写入超时时间
+// 调用方的 ctx 已经结束，只能使用独立的短超时
+const cancelWriteTimeout = time.Second
+
+// cancelTombstoneTTL 提前到达的取消通知的保留时间
+// 取消通知在读循环中直接处理，可能先于对应的请求登记到在途请求表，
+// 此时记录下来，请求在保留时间内登记时立即被取消
+const cancelTombstoneTTL = 5 * time.Second
+
+// maxCancelTombstones 每个连接最多保留的提前到达的取消通知数
+const maxCancelTombstones = 256
+
+// CancelParams $/cancelRequest 的参数
+type CancelParams struct {
+	ID interface{} `json:"id"` // 要取消的请求 ID
+}
+
+// inflightRequest 正在处理的请求
+type inflightRequest struct {
+	cancel context.CancelFunc
+}
+
+// trackRequest 将请求登记到连接的在途请求表
+// 返回可被 $/cancelRequest 取消的 ctx，以及请求结束时调用的清理函数
+// 取消通知先于请求到达时，返回的 ctx 已经被取消
+func (sc *ServerConn) trackRequest(ctx context.Context, id interface{}) (context.Context, func()) {
+	// 只有字符串和数字 ID 可以作为 map 键，其他类型（非法 ID）不支持取消
+	if !isCancellableID(id) {
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	entry := &inflightRequest{cancel: cancel}
+
+	sc.mu.Lock()
+	if expires, ok := sc.tombstones[id]; ok {
+		delete(sc.tombstones, id)
+		if time.Now().Before(expires) {
+			sc.mu.Unlock()
+			atomic.AddUint64(&sc.server.cancelled, 1)
+			cancel()
+			return ctx, cancel
+		}
+	}
+	if sc.requests == nil {
+		sc.requests = make(map[interface{}]*inflightRequest)
+	}
+	// ID 重复时后到的请求覆盖先到的请求
+	sc.requests[id] = entry
+	sc.mu.Unlock()
+
+	return ctx, func() {
+		sc.mu.Lock()
+		if sc.requests[id] == entry {
+			delete(sc.requests, id)
+		}
+		sc.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancelRequest 取消连接上指定 ID 的请求
+// 请求已经结束或尚未登记时返回 false，后一种情况由 trackRequest 在登记时取消
+// 先计数再取消，服务方法观察到取消时统计信息已经更新
+func (sc *ServerConn) cancelRequest(id interface{}) bool {
+	if !isCancellableID(id) {
+		return false
+	}
+
+	sc.mu.Lock()
+	entry, ok := sc.requests[id]
+	if ok {
+		delete(sc.requests, id)
+	} else {
+		sc.addTombstone(id)
+	}
+	sc.mu.Unlock()
+
+	if ok {
+		atomic.AddUint64(&sc.server.cancelled, 1)
+		entry.cancel()
+	}
+	return ok
+}
+
+// addTombstone 记录尚未登记的请求的取消通知，调用者必须持有 sc.mu
+// 请求已经结束时记录会在过期后清理；达到数量上限时先清理过期记录，仍然已满则丢弃
+func (sc *ServerConn) addTombstone(id interface{}) {
+	now := time.Now()
+	if len(sc.tombstones) >= maxCancelTombstones {
+		for key, expires := range sc.tombstones {
+			if !now.Before(expires) {
+				delete(sc.tombstones, key)
+			}
+		}
+		if len(sc.tombstones) >= maxCancelTombstones {
+			return
+		}
+	}
+	if sc.tombstones == nil {
+		sc.tombstones = make(map[interface{}]time.Time)
+	}
+	sc.tombstones[id] = now.Add(cancelTombstoneTTL)
+}
+
+// isCancellableID 判断请求 ID 是否可以登记到在途请求表
+// 解码后的数字 ID 为 float64，字符串 ID 为 string
+func isCancellableID(id interface{}) bool {
+	switch id.(type) {
+	case float64, string:
+		return true
+	default:
+		return false
+	}
+}
+
+// handleCancel 处理 $/cancelRequest 通知
+// HTTP 传输没有长连接，每个请求的 ctx 随 HTTP 请求取消，这里直接忽略
+func (s *Server) handleCancel(ctx context.Context, params json.RawMessage) {
+	sc, ok := ServerConnFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	var p CancelParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return
+	}
+
+	sc.cancelRequest(p.ID)
+}
+
+// cancelInline 在读循环中直接处理取消通知，返回消息是否为取消通知
+// 取消通知不产生响应，不占用连接的并发名额，也不进入严格顺序模式的响应队列：
+// 否则连接的并发名额被占满时读循环阻塞，取消通知无法到达正在执行的请求
+func (s *Server) cancelInline(ctx context.Context, data []byte) bool {
+	if isBatch(data) || !bytes.Contains(data, cancelMethodBytes) {
+		return false
+	}
+
+	var msg struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+		ID     json.RawMessage `json:"id"`
+	}
+	// 带 ID 的取消请求需要响应，按普通请求处理
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Method != CancelRequestMethod || msg.ID != nil {
+		return false
+	}
+
+	s.handleCancel(ctx, msg.Params)
+	return true
+}
+
+// encodeCancel 编码取消指定请求的通知
+func (c *Client) encodeCancel(seq uint64) ([]byte, error) {
+	req := c.codec.(*JSONCodec).pool.GetRequest()
+	defer c.codec.(*JSONCodec).pool.PutRequest(req)
+
+	params, err := json.Marshal(CancelParams{ID: seq})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cancel params: %w", err)
+	}
+
+	req.Jsonrpc = JSONRPCVersion
+	req.Method = CancelRequestMethod
+	req.Params = params
+
+	return c.codec.EncodeRequest(req)
+}
@@ -3,11 +3,14 @@ package rerpc
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -33,6 +36,11 @@ type Call struct {
 	Error         error       // 调用错误
 	Done          chan *Call  // 调用完成通知 channel（异步调用使用）
 	seq           uint64      // 请求序列号
+	trailer       *Metadata   // 接收响应元数据（WithTrailer）
+
+	// 多路复用模式
+	mc   *muxConn       // 请求所在的连接
+	resp chan *Response // 读协程投递的响应，连接失效时投递 nil
 }
 
 // Client RPC 客户端
@@ -52,17 +60,87 @@ type Client struct {
 	// 重试配置
 	maxRetries  int           // 最大重试次数
 	retryDelay  time.Duration // 重试延迟（指数退避）
+
+	// 多路复用配置
+	multiplex bool       // 是否启用多路复用模式
+	muxConns  []*muxConn // 多路复用长连接
+	muxMu     sync.Mutex // 保护 muxConns
+	muxNext   uint32     // 轮询计数（原子递增）
+
+	// HTTP 传输
+	httpURL    string       // 服务端 URL，非空时使用 HTTP 传输
+	httpClient *http.Client // HTTP 客户端
+
+	// WebSocket 传输
+	wsURL       string        // 服务端 URL，非空时使用 WebSocket 传输（总是多路复用）
+	dialTimeout time.Duration // WebSocket 握手超时时间
+	tlsConfig   *tls.Config   // wss:// 使用的 TLS 配置
+
+	// 服务端推送的通知回调
+	onNotification func(method string, params json.RawMessage)
+
+	maxResponseBytes int // 单条响应消息的最大字节数
+
+	logger *slog.Logger // 日志
+
+	// 包装了客户端拦截器的 Invoker
+	invoker Invoker
+
+	credentials Credentials // 为每个请求生成认证元数据，为 nil 时不认证
+
+	cancelled uint64 // 发送 $/cancelRequest 的次数（原子操作）
 }
 
 // ClientConfig 客户端配置
 type ClientConfig struct {
 	Network     string        // 网络类型（如 "tcp"）
-	Address     string        // 服务器地址（如 "localhost:8080"）
+	Address     string        // 服务器地址（如 "localhost:8080"），以 http(s):// 开头时使用 HTTP 传输，以 ws(s):// 开头时使用 WebSocket 传输
 	MaxIdle     int           // 最大空闲连接数
 	MaxActive   int           // 最大活跃连接数
 	DialTimeout time.Duration // 连接超时时间
 	MaxRetries  int           // 最大重试次数
 	RetryDelay  time.Duration // 重试延迟
+
+	// Multiplex 启用多路复用模式
+	// 多个并发调用共享少量长连接，响应可以乱序返回，由读协程按 ID 分发
+	Multiplex bool
+	// MuxConns 多路复用模式下的长连接数量（默认 1）
+	MuxConns int
+
+	// HTTPClient HTTP 传输使用的客户端（可选）
+	// 为空时根据 MaxIdle、MaxActive、DialTimeout 创建
+	HTTPClient *http.Client
+
+	// TLSConfig TLS 配置（可选）
+	// 用于 TCP 连接、https:// 和 wss:// 地址；需要客户端证书（mTLS）时设置 Certificates
+	// 为 TCP 连接设置时若 ServerName 为空，使用 Address 中的主机名
+	TLSConfig *tls.Config
+
+	// Middlewares 客户端拦截器（可选），先配置的位于外层
+	Middlewares []ClientMiddleware
+
+	// OnNotification 接收服务端推送的通知（可选）
+	// 仅在多路复用模式（包括 WebSocket 传输）下生效，在读协程中同步调用，不应长时间阻塞
+	OnNotification func(method string, params json.RawMessage)
+
+	// MaxResponseBytes 单条响应消息（包括服务端推送的通知）的最大字节数，默认 32MB
+	// 超过限制时调用返回 ErrMessageTooLarge，连接被丢弃
+	MaxResponseBytes int
+
+	// Logger 日志（默认 slog.Default()），记录重试、长连接断开和丢弃的消息，同时用于连接池
+	Logger *slog.Logger
+
+	// Metrics 指标（可选），记录每次调用的耗时和结果，位于所有拦截器的外层
+	// 实现了 ConnPoolCollector 时自动注册客户端的连接池
+	Metrics Metrics
+
+	// Tracer 链路追踪（可选），为每次调用创建一个客户端 span，位于 Metrics 之内、所有拦截器的外层
+	// 无论是否配置，ctx 中的链路信息都会通过请求的 traceparent 和 tracestate 成员传给服务端
+	Tracer Tracer
+
+	// Credentials 客户端凭据（可选），如 NewBearerCredentials、NewHMACCredentials
+	// 为每个请求（包括重试和通知）生成认证元数据
+	Credentials Credentials
 }
 
 // NewClient 创建一个新的 RPC 客户端
@@ -94,6 +172,62 @@ func NewClient(config ClientConfig) (*Client, error) {
 	if config.RetryDelay <= 0 {
 		config.RetryDelay = 100 * time.Millisecond
 	}
+	if config.MuxConns <= 0 {
+		config.MuxConns = 1
+	}
+	if config.MaxResponseBytes <= 0 {
+		config.MaxResponseBytes = defaultMaxMessageSize
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	if config.Multiplex && config.MaxActive < config.MuxConns {
+		config.MaxActive = config.MuxConns
+	}
+
+	// HTTP 传输：连接由 http.Client 管理，不需要连接池
+	if isHTTPAddress(config.Address) {
+		httpClient := config.HTTPClient
+		if httpClient == nil {
+			httpClient = newHTTPClient(config)
+		}
+		client := &Client{
+			codec:      NewJSONCodec(nil),
+			pending:    make(map[uint64]*Call),
+			maxRetries: config.MaxRetries,
+			retryDelay: config.RetryDelay,
+			httpURL:    config.Address,
+			httpClient: httpClient,
+
+			maxResponseBytes: config.MaxResponseBytes,
+			logger:           config.Logger,
+			credentials:      config.Credentials,
+		}
+		client.invoker = chainClientMiddlewares(clientMiddlewares(config), client.invoke)
+		return client, nil
+	}
+
+	// WebSocket 传输：每个长连接都是独立的 WebSocket 会话，总是使用多路复用模式
+	if isWebSocketAddress(config.Address) {
+		client := &Client{
+			codec:          NewJSONCodec(nil),
+			pending:        make(map[uint64]*Call),
+			maxRetries:     config.MaxRetries,
+			retryDelay:     config.RetryDelay,
+			multiplex:      true,
+			muxConns:       make([]*muxConn, config.MuxConns),
+			wsURL:          config.Address,
+			dialTimeout:    config.DialTimeout,
+			tlsConfig:      config.TLSConfig,
+			onNotification: config.OnNotification,
+
+			maxResponseBytes: config.MaxResponseBytes,
+			logger:           config.Logger,
+			credentials:      config.Credentials,
+		}
+		client.invoker = chainClientMiddlewares(clientMiddlewares(config), client.invoke)
+		return client, nil
+	}
 
 	// 创建连接池
 	connPool, err := NewConnPool(ConnPoolConfig{
@@ -103,6 +237,8 @@ func NewClient(config ClientConfig) (*Client, error) {
 		MaxActive:   config.MaxActive,
 		DialTimeout: config.DialTimeout,
 		TestOnGet:   true, // 启用连接健康检查
+		TLSConfig:   config.TLSConfig,
+		Logger:      config.Logger,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
@@ -115,17 +251,54 @@ func NewClient(config ClientConfig) (*Client, error) {
 		pending:     make(map[uint64]*Call),
 		maxRetries:  config.MaxRetries,
 		retryDelay:  config.RetryDelay,
+		multiplex:   config.Multiplex,
+
+		onNotification: config.OnNotification,
+
+		maxResponseBytes: config.MaxResponseBytes,
+		logger:           config.Logger,
+		credentials:      config.Credentials,
+	}
+
+	if config.Multiplex {
+		client.muxConns = make([]*muxConn, config.MuxConns)
+	}
+	if collector, ok := config.Metrics.(ConnPoolCollector); ok {
+		collector.RegisterConnPool(config.Address, connPool)
 	}
+	client.invoker = chainClientMiddlewares(clientMiddlewares(config), client.invoke)
 
 	return client, nil
 }
 
+// clientMiddlewares 返回客户端使用的拦截器
+// 配置了 Metrics 时在最外层加入记录指标的拦截器，配置了 Tracer 时在其内加入创建 span 的拦截器
+func clientMiddlewares(config ClientConfig) []ClientMiddleware {
+	var builtin []ClientMiddleware
+	if config.Metrics != nil {
+		builtin = append(builtin, metricsMiddleware(config.Metrics))
+	}
+	if config.Tracer != nil {
+		builtin = append(builtin, tracingMiddleware(config.Tracer))
+	}
+	if len(builtin) == 0 {
+		return config.Middlewares
+	}
+	return append(builtin, config.Middlewares...)
+}
+
 // nextSeq 生成下一个请求序列号
 // 使用 atomic 操作确保线程安全
 func (c *Client) nextSeq() uint64 {
 	return atomic.AddUint64(&c.seq, 1)
 }
 
+// connOptions 返回长连接使用的读写选项
+// 客户端不设置读取超时，调用的超时由 ctx 控制
+func (c *Client) connOptions() connOptions {
+	return connOptions{maxMessageSize: c.maxResponseBytes}
+}
+
 // isClosed 检查客户端是否已关闭
 func (c *Client) isClosed() bool {
 	return atomic.LoadInt32(&c.closed) == 1
@@ -159,12 +332,24 @@ func (c *Client) Call(ctx context.Context, serviceMethod string, args, reply int
 		return errors.New("reply must not be nil")
 	}
 
+	// 经过拦截器链执行调用
+	return c.invoker(ctx, serviceMethod, args, reply)
+}
+
+// invoke 拦截器链最内层的 Invoker
+// reply 为 nil 时发送通知，否则执行带重试的调用
+func (c *Client) invoke(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	if reply == nil {
+		return c.notify(ctx, serviceMethod, args)
+	}
+
 	// 创建 Call 对象
 	call := &Call{
 		ServiceMethod: serviceMethod,
 		Args:          args,
 		Reply:         reply,
 		Done:          make(chan *Call, 1), // buffered channel，避免阻塞
+		trailer:       trailerDest(ctx),
 	}
 
 	// 执行调用（带重试）
@@ -180,8 +365,12 @@ func (c *Client) Call(ctx context.Context, serviceMethod string, args, reply int
 		// 尝试执行调用
 		err := c.doCall(ctx, call)
 		if err == nil {
-			// 调用成功
-			return call.Error
+			// 调用完成，服务端限流且还有重试次数时按 retry-after 提示重试
+			if _, limited := RetryAfter(call.Error); !limited || attempt == c.maxRetries {
+				return call.Error
+			}
+			err = call.Error
+			call.Error = nil
 		}
 
 		lastErr = err
@@ -194,7 +383,17 @@ func (c *Client) Call(ctx context.Context, serviceMethod string, args, reply int
 		// 如果不是最后一次尝试，等待后重试
 		if attempt < c.maxRetries {
 			// 指数退避：每次重试延迟时间翻倍
+			// 服务端限流时至少等待其建议的时间，超过 ctx 的截止时间则不再重试
 			delay := c.retryDelay * time.Duration(1<<uint(attempt))
+			if retryAfter, ok := RetryAfter(err); ok {
+				if retryAfter > delay {
+					delay = retryAfter
+				}
+				if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+					return err
+				}
+			}
+			c.logger.Debug("retrying call", "method", serviceMethod, "attempt", attempt+1, "delay", delay, "error", err)
 			
 			select {
 			case <-time.After(delay):
@@ -211,6 +410,16 @@ func (c *Client) Call(ctx context.Context, serviceMethod string, args, reply int
 // doCall 执行单次 RPC 调用
 // 这是 Call 方法的核心实现，不包含重试逻辑
 func (c *Client) doCall(ctx context.Context, call *Call) error {
+	// HTTP 传输：每次调用一个 POST 请求
+	if c.httpClient != nil {
+		return c.doHTTPCall(ctx, call)
+	}
+
+	// 多路复用模式：共享长连接，响应由读协程分发
+	if c.multiplex {
+		return c.doMuxCall(ctx, call)
+	}
+
 	// 生成请求序列号
 	seq := c.nextSeq()
 	call.seq = seq
@@ -221,11 +430,7 @@ func (c *Client) doCall(ctx context.Context, call *Call) error {
 	c.mu.Unlock()
 
 	// 确保在函数返回时清理 pending
-	defer func() {
-		c.mu.Lock()
-		delete(c.pending, seq)
-		c.mu.Unlock()
-	}()
+	defer c.removePending(seq)
 
 	// 从连接池获取连接
 	conn, err := c.connPool.Get()
@@ -233,30 +438,22 @@ func (c *Client) doCall(ctx context.Context, call *Call) error {
 		return fmt.Errorf("%w: %v", ErrNoConnection, err)
 	}
 
-	// 确保连接被归还
-	defer c.connPool.Put(conn)
-
-	// 编码请求
-	req := c.codec.(*JSONCodec).pool.GetRequest()
-	defer c.codec.(*JSONCodec).pool.PutRequest(req)
-
-	req.Jsonrpc = JSONRPCVersion
-	req.Method = call.ServiceMethod
-	req.ID = seq
-
-	// 序列化参数
-	if call.Args != nil {
-		argsData, err := json.Marshal(call.Args)
-		if err != nil {
-			return fmt.Errorf("failed to marshal args: %w", err)
+	// 只有完整收到响应的连接才能归还连接池
+	// 写入失败、超时或取消时连接上可能残留未读的响应，必须丢弃
+	reusable := false
+	defer func() {
+		if reusable {
+			c.connPool.Put(conn)
+		} else {
+			c.connPool.Discard(conn)
 		}
-		req.Params = argsData
-	}
+	}()
 
 	// 编码请求消息
-	reqData, err := c.codec.EncodeRequest(req)
+	reqData, err := c.encodeCall(ctx, seq, call)
 	if err != nil {
-		return fmt.Errorf("failed to encode request: %w", err)
+		reusable = true // 尚未写入任何数据
+		return err
 	}
 
 	// 发送请求
@@ -277,8 +474,16 @@ func (c *Client) doCall(ctx context.Context, call *Call) error {
 	// 等待响应或超时
 	select {
 	case err := <-respChan:
+		reusable = err == nil
 		return err
 	case <-ctx.Done():
+		// 通知服务端取消请求，随后丢弃连接（读协程会因连接关闭而退出）
+		if data, err := c.encodeCancel(seq); err == nil {
+			conn.SetWriteDeadline(time.Now().Add(cancelWriteTimeout))
+			if _, err := conn.Write(data); err == nil {
+				atomic.AddUint64(&c.cancelled, 1)
+			}
+		}
 		return ctx.Err()
 	}
 }
@@ -287,7 +492,7 @@ func (c *Client) doCall(ctx context.Context, call *Call) error {
 func (c *Client) receiveResponse(conn net.Conn, call *Call) error {
 	// 读取响应
 	reader := bufio.NewReader(conn)
-	respData, err := reader.ReadBytes('\n')
+	respData, err := readLine(reader, c.maxResponseBytes)
 	if err != nil {
 		if err == io.EOF {
 			return errors.New("connection closed by server")
@@ -311,6 +516,16 @@ func (c *Client) receiveResponse(conn net.Conn, call *Call) error {
 		return fmt.Errorf("response ID mismatch: expected %d, got %d", call.seq, uint64(respID))
 	}
 
+	return c.handleResponse(resp, call)
+}
+
+// handleResponse 将响应写入 Call
+// 错误响应保存到 call.Error，成功响应反序列化到 call.Reply
+func (c *Client) handleResponse(resp *Response, call *Call) error {
+	if call.trailer != nil {
+		*call.trailer = resp.Trailer
+	}
+
 	// 处理错误响应
 	if resp.Error != nil {
 		call.Error = resp.Error
@@ -348,6 +563,11 @@ func (c *Client) shouldRetry(err error) bool {
 		return false
 	}
 
+	// 服务端限流，按 retry-after 提示等待后重试
+	if _, ok := RetryAfter(err); ok {
+		return true
+	}
+
 	// 网络错误，重试
 	var netErr net.Error
 	if errors.As(err, &netErr) {
@@ -363,6 +583,100 @@ func (c *Client) shouldRetry(err error) bool {
 	return false
 }
 
+// Notify 发送 JSON-RPC 通知（不带 id 的请求）
+// 参数：
+//   - ctx: 上下文，用于取消和写入超时控制
+//   - serviceMethod: 服务方法名（格式：Service.Method）
+//   - args: 方法参数
+// 返回：
+//   - error: 发送失败时返回错误
+//
+// 服务端不会返回任何响应（包括错误），因此请求写出后立即返回
+// 通知不分配序列号，也不会进入 pending 映射
+func (c *Client) Notify(ctx context.Context, serviceMethod string, args interface{}) error {
+	// 检查客户端是否已关闭
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+
+	if serviceMethod == "" {
+		return errors.New("service method is required")
+	}
+
+	// 经过拦截器链发送通知，reply 为 nil
+	return c.invoker(ctx, serviceMethod, args, nil)
+}
+
+// notify 编码并发送通知
+func (c *Client) notify(ctx context.Context, serviceMethod string, args interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 编码通知，ID 为 nil 时不会序列化 id 成员
+	req := c.codec.(*JSONCodec).pool.GetRequest()
+	defer c.codec.(*JSONCodec).pool.PutRequest(req)
+
+	req.Jsonrpc = JSONRPCVersion
+	req.Method = serviceMethod
+	req.Timeout = requestTimeout(ctx)
+	injectTraceContext(ctx, req)
+
+	if args != nil {
+		argsData, err := json.Marshal(args)
+		if err != nil {
+			return fmt.Errorf("failed to marshal args: %w", err)
+		}
+		req.Params = argsData
+	}
+	if err := c.setRequestMetadata(ctx, req); err != nil {
+		return err
+	}
+
+	reqData, err := c.codec.EncodeRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	// HTTP 传输
+	if c.httpClient != nil {
+		return c.notifyHTTP(ctx, reqData)
+	}
+
+	// 多路复用模式：直接写入共享长连接
+	if c.multiplex {
+		mc, err := c.getMuxConn()
+		if err != nil {
+			return err
+		}
+		return mc.write(ctx, reqData)
+	}
+
+	// 从连接池获取连接
+	conn, err := c.connPool.Get()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoConnection, err)
+	}
+
+	// 使用 context 的截止时间作为写入超时
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		conn.SetWriteDeadline(deadline)
+	}
+
+	if _, err := conn.Write(reqData); err != nil {
+		// 写入失败的连接不能再复用
+		c.connPool.Discard(conn)
+		return fmt.Errorf("failed to write notification: %w", err)
+	}
+
+	if hasDeadline {
+		conn.SetWriteDeadline(time.Time{})
+	}
+	c.connPool.Put(conn)
+	return nil
+}
+
 // Go 执行异步 RPC 调用
 // 参数：
 //   - serviceMethod: 服务方法名（格式：Service.Method）
@@ -522,9 +836,21 @@ func (c *Client) Close() error {
 		return nil // 已经关闭
 	}
 
-	// 关闭连接池
-	if err := c.connPool.Close(); err != nil {
-		return fmt.Errorf("failed to close connection pool: %w", err)
+	// 关闭多路复用连接，等待中的调用会收到 ErrClientClosed
+	if c.multiplex {
+		c.closeMuxConns()
+	}
+
+	// HTTP 传输：释放空闲连接
+	if c.httpClient != nil {
+		c.httpClient.CloseIdleConnections()
+	}
+
+	// 关闭连接池（HTTP 传输没有连接池）
+	if c.connPool != nil {
+		if err := c.connPool.Close(); err != nil {
+			return fmt.Errorf("failed to close connection pool: %w", err)
+		}
 	}
 
 	// 清理待处理的调用
@@ -553,14 +879,25 @@ func (c *Client) Ping() error {
 		return ErrClientClosed
 	}
 
+	if c.httpClient != nil {
+		return c.pingHTTP()
+	}
+
+	// WebSocket 传输没有连接池，通过建立（或复用）长连接验证
+	if c.connPool == nil {
+		_, err := c.getMuxConn()
+		return err
+	}
+
 	return c.connPool.Ping()
 }
 
 // Stats 返回客户端的统计信息
 type ClientStats struct {
-	PendingCalls int       // 待处理的调用数量
-	PoolStats    PoolStats // 连接池统计信息
-	IsClosed     bool      // 是否已关闭
+	PendingCalls   int       // 待处理的调用数量
+	CancelledCalls uint64    // 因 context 取消或超时而发送 $/cancelRequest 的调用数量
+	PoolStats      PoolStats // 连接池统计信息
+	IsClosed       bool      // 是否已关闭
 }
 
 // Stats 获取客户端统计信息
@@ -569,11 +906,15 @@ func (c *Client) Stats() ClientStats {
 	pendingCount := len(c.pending)
 	c.mu.Unlock()
 
-	return ClientStats{
-		PendingCalls: pendingCount,
-		PoolStats:    c.connPool.Stats(),
-		IsClosed:     c.isClosed(),
+	stats := ClientStats{
+		PendingCalls:   pendingCount,
+		CancelledCalls: atomic.LoadUint64(&c.cancelled),
+		IsClosed:       c.isClosed(),
+	}
+	if c.connPool != nil {
+		stats.PoolStats = c.connPool.Stats()
 	}
+	return stats
 }
 
 // SetMaxRetries 设置最大重试次数
@@ -2,6 +2,7 @@ package rerpc
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 )
 
@@ -86,6 +87,11 @@ func (c *JSONCodec) DecodeRequest(data []byte) (*Request, error) {
 	if err := json.Unmarshal(data, req); err != nil {
 		// 解码失败，归还对象
 		c.pool.PutRequest(req)
+		// JSON 合法但字段类型不符（如 "method":1）属于无效请求，而非解析错误
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, NewInvalidRequestError(err.Error())
+		}
 		return nil, NewParseError(err.Error())
 	}
 
@@ -101,6 +107,17 @@ func (c *JSONCodec) DecodeRequest(data []byte) (*Request, error) {
 		return nil, NewInvalidRequestError("method is required")
 	}
 
+	// 区分缺省 id（通知）和 "id":null
+	// 性能优化：只有 ID 为 nil 时才需要二次解析
+	if req.ID == nil {
+		var probe struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.Unmarshal(data, &probe); err == nil {
+			req.nullID = probe.ID != nil
+		}
+	}
+
 	// 注意：调用者负责在使用完毕后归还 Request 对象
 	return req, nil
 }
@@ -0,0 +1,234 @@
+package rerpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// messageConn 面向消息的连接
+// 屏蔽不同传输的分帧方式：TCP 按换行符分隔，WebSocket 每条消息一个数据帧
+// ReadMessage 只允许一个读协程调用；WriteMessage 是并发安全的
+type messageConn interface {
+	// ReadMessage 读取一条完整的 JSON-RPC 消息
+	ReadMessage() ([]byte, error)
+
+	// WriteMessage 写入一条完整的 JSON-RPC 消息
+	// deadline 为零值时不设置写入超时
+	WriteMessage(data []byte, deadline time.Time) error
+
+	// RemoteAddr 返回对端地址
+	RemoteAddr() net.Addr
+
+	// Close 关闭连接
+	Close() error
+}
+
+// defaultMaxMessageSize 单条消息的默认最大字节数
+const defaultMaxMessageSize = 32 << 20
+
+// ErrMessageTooLarge 表示读取的消息超过大小限制
+// 服务端收到过大的请求时返回错误响应并关闭连接，客户端收到过大的响应时调用失败
+var ErrMessageTooLarge = errors.New("message too large")
+
+// connOptions 面向消息的连接的读写选项
+// 空闲超时和消息读取超时分开计算：连接可以长时间空闲，但一条消息开始后必须尽快读完，
+// 避免逐字节慢速发送的客户端（slow loris）长期占用连接和 worker
+type connOptions struct {
+	maxMessageSize  int           // 单条消息的最大字节数，0 表示不限制
+	idleTimeout     time.Duration // 等待下一条消息的超时时间，0 表示不超时
+	readTimeout     time.Duration // 从消息第一个字节到达起读完整条消息的超时时间，0 表示不超时
+	readBufferSize  int           // 读缓冲大小，0 表示 32KB
+	writeBufferSize int           // 写缓冲大小，0 表示 32KB
+}
+
+// bufferSize 返回缓冲大小，size <= 0 时使用默认的 32KB
+func bufferSize(size int) int {
+	if size <= 0 {
+		return defaultBufferSize
+	}
+	return size
+}
+
+// waitMessage 等待下一条消息的第一个字节（最长 idleTimeout），随后设置整条消息的读取超时
+// 新连接的第一条消息同样按空闲超时等待：客户端连接池会提前建立连接，使用前不发送任何数据
+func (l connOptions) waitMessage(conn net.Conn, reader *bufio.Reader) error {
+	if l.idleTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(l.idleTimeout))
+	} else {
+		conn.SetReadDeadline(time.Time{})
+	}
+	if l.readTimeout <= 0 {
+		return nil
+	}
+	if _, err := reader.Peek(1); err != nil {
+		return err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(l.readTimeout))
+	return nil
+}
+
+// readLine 读取一行数据（包括结尾的换行符）
+// 不含换行符超过 max 字节（max > 0）时返回 ErrMessageTooLarge，不会继续缓存剩余数据
+func readLine(reader *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		frag, err := reader.ReadSlice('\n')
+		size := len(line) + len(frag)
+		if err == nil {
+			size-- // 不计算结尾的换行符
+		}
+		if max > 0 && size > max {
+			return nil, ErrMessageTooLarge
+		}
+		line = append(line, frag...)
+		if err == nil {
+			return line, nil
+		}
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// readAll 读取全部数据，超过 max 字节（max > 0）时返回 ErrMessageTooLarge
+// 用于 HTTP 请求体和响应体
+func readAll(r io.Reader, max int) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > max {
+		return nil, ErrMessageTooLarge
+	}
+	return data, nil
+}
+
+// lineConn 以换行符分隔消息的连接（TCP、Unix socket 等）
+// 性能优化：使用 bufio 减少系统调用
+type lineConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	limits connOptions // 读写选项
+
+	wmu    sync.Mutex    // 串行化写入
+	writer *bufio.Writer // 写缓冲
+}
+
+// newLineConn 包装连接
+func newLineConn(conn net.Conn, opts connOptions) *lineConn {
+	return &lineConn{
+		conn:   conn,
+		reader: bufio.NewReaderSize(conn, bufferSize(opts.readBufferSize)),
+		writer: bufio.NewWriterSize(conn, bufferSize(opts.writeBufferSize)),
+		limits: opts,
+	}
+}
+
+// ReadMessage 读取一行数据
+func (c *lineConn) ReadMessage() ([]byte, error) {
+	if err := c.limits.waitMessage(c.conn, c.reader); err != nil {
+		return nil, err
+	}
+	return readLine(c.reader, c.limits.maxMessageSize)
+}
+
+// WriteMessage 写入一行数据并刷新缓冲区
+func (c *lineConn) WriteMessage(data []byte, deadline time.Time) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if !deadline.IsZero() {
+		c.conn.SetWriteDeadline(deadline)
+		defer c.conn.SetWriteDeadline(time.Time{})
+	}
+
+	if _, err := c.writer.Write(data); err != nil {
+		return err
+	}
+	return c.writer.Flush()
+}
+
+// RemoteAddr 返回对端地址
+func (c *lineConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close 关闭连接
+func (c *lineConn) Close() error {
+	return c.conn.Close()
+}
+
+// ServerConn 服务端持有的客户端连接
+// 服务方法可以通过 ServerConnFromContext 获取当前连接，向客户端推送通知
+// HTTP 传输没有长连接，不提供 ServerConn
+type ServerConn struct {
+	server *Server
+	conn   messageConn
+
+	mu         sync.Mutex                       // 保护 requests 和 tombstones
+	requests   map[interface{}]*inflightRequest // 正在处理的请求（按请求 ID），用于 $/cancelRequest
+	tombstones map[interface{}]time.Time        // 先于请求到达的取消通知（按请求 ID）及其过期时间
+}
+
+// serverConnKey context 中保存 *ServerConn 的键
+type serverConnKey struct{}
+
+// ServerConnFromContext 获取处理当前请求的连接
+// 仅在 TCP 和 WebSocket 传输上可用
+func ServerConnFromContext(ctx context.Context) (*ServerConn, bool) {
+	sc, ok := ctx.Value(serverConnKey{}).(*ServerConn)
+	return sc, ok
+}
+
+// RemoteAddr 返回客户端地址
+func (sc *ServerConn) RemoteAddr() net.Addr {
+	return sc.conn.RemoteAddr()
+}
+
+// Notify 向客户端推送一条通知（不带 id 的请求）
+// 客户端需要启用多路复用模式（或使用 WebSocket）并设置 OnNotification 才能接收
+func (sc *ServerConn) Notify(method string, params interface{}) error {
+	req := GetRequest()
+	defer PutRequest(req)
+
+	req.Jsonrpc = JSONRPCVersion
+	req.Method = method
+
+	if params != nil {
+		paramsData, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to marshal params: %w", err)
+		}
+		req.Params = paramsData
+	}
+
+	data, err := sc.server.codec.EncodeRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	return sc.write(data)
+}
+
+// write 写入一条消息
+func (sc *ServerConn) write(data []byte) error {
+	// 设置写入超时
+	if err := sc.conn.WriteMessage(data, time.Now().Add(sc.server.writeTimeout)); err != nil {
+		return err
+	}
+	if sc.server.metrics != nil {
+		sc.server.metrics.BytesSent(len(data))
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package rerpc
 
 import (
+	"crypto/tls"
 	"errors"
+	"log/slog"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -51,6 +53,13 @@ type ConnPool struct {
 	// 状态管理
 	closed int32      // 关闭标志（使用 atomic 操作）
 	mu     sync.Mutex // 保护关闭操作
+
+	logger *slog.Logger // 日志
+
+	// 统计信息（原子操作）
+	dials     uint64 // 建立的连接数
+	reuses    uint64 // 复用空闲连接的次数
+	evictions uint64 // 因不健康或空闲队列已满而关闭的连接数
 }
 
 // ConnPoolConfig 连接池配置
@@ -62,6 +71,8 @@ type ConnPoolConfig struct {
 	DialTimeout time.Duration // 连接超时时间
 	IdleTimeout time.Duration // 空闲连接超时时间
 	TestOnGet   bool          // 获取连接时是否进行健康检查
+	TLSConfig   *tls.Config   // TLS 配置，为 nil 时使用明文 TCP
+	Logger      *slog.Logger  // 日志（默认 slog.Default()），记录建立连接失败和淘汰的连接
 }
 
 // NewConnPool 创建一个新的连接池
@@ -89,6 +100,9 @@ func NewConnPool(config ConnPoolConfig) (*ConnPool, error) {
 	if config.IdleTimeout <= 0 {
 		config.IdleTimeout = 5 * time.Minute // 默认空闲超时
 	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
 
 	pool := &ConnPool{
 		network:     config.Network,
@@ -99,12 +113,21 @@ func NewConnPool(config ConnPoolConfig) (*ConnPool, error) {
 		dialTimeout: config.DialTimeout,
 		idleTimeout: config.IdleTimeout,
 		testOnGet:   config.TestOnGet,
+		logger:      config.Logger,
 	}
 
 	// 设置默认的连接工厂函数
 	pool.dial = func() (net.Conn, error) {
 		return net.DialTimeout(pool.network, pool.address, pool.dialTimeout)
 	}
+	if config.TLSConfig != nil {
+		// 建立连接时完成 TLS 握手，握手超时计入连接超时
+		tlsConfig := config.TLSConfig
+		pool.dial = func() (net.Conn, error) {
+			dialer := &net.Dialer{Timeout: pool.dialTimeout}
+			return tls.DialWithDialer(dialer, pool.network, pool.address, tlsConfig)
+		}
+	}
 
 	// 设置默认的连接健康检查函数
 	pool.testConn = func(conn net.Conn) error {
@@ -140,12 +163,15 @@ func (p *ConnPool) Get() (net.Conn, error) {
 		if p.testOnGet && p.testConn != nil {
 			if err := p.testConn(conn); err != nil {
 				// 连接不健康，关闭并递减活跃连接计数
+				p.logger.Debug("evicting unhealthy connection", "address", p.address, "error", err)
 				conn.Close()
 				atomic.AddInt32(&p.activeNum, -1)
+				atomic.AddUint64(&p.evictions, 1)
 				// 递归调用，尝试获取另一个连接
 				return p.Get()
 			}
 		}
+		atomic.AddUint64(&p.reuses, 1)
 		return conn, nil
 	default:
 		// 空闲队列为空，进入慢速路径
@@ -163,11 +189,13 @@ func (p *ConnPool) Get() (net.Conn, error) {
 	// 创建新连接
 	conn, err := p.dial()
 	if err != nil {
+		p.logger.Warn("dial failed", "network", p.network, "address", p.address, "error", err)
 		return nil, err
 	}
 
 	// 增加活跃连接计数
 	atomic.AddInt32(&p.activeNum, 1)
+	atomic.AddUint64(&p.dials, 1)
 
 	return conn, nil
 }
@@ -197,10 +225,22 @@ func (p *ConnPool) Put(conn net.Conn) error {
 		// 空闲队列已满，关闭连接并递减活跃连接计数
 		conn.Close()
 		atomic.AddInt32(&p.activeNum, -1)
+		atomic.AddUint64(&p.evictions, 1)
 		return nil
 	}
 }
 
+// Discard 关闭并丢弃一个连接，不放回空闲队列
+// 用于已损坏的连接，或由调用者长期持有后不再复用的连接
+func (p *ConnPool) Discard(conn net.Conn) error {
+	if conn == nil {
+		return ErrInvalidConn
+	}
+
+	atomic.AddInt32(&p.activeNum, -1)
+	return conn.Close()
+}
+
 // ActiveCount 返回当前活跃连接数
 func (p *ConnPool) ActiveCount() int {
 	return int(atomic.LoadInt32(&p.activeNum))
@@ -307,9 +347,12 @@ func (p *ConnPool) SetTestFunc(test func(net.Conn) error) {
 
 // Stats 返回连接池的统计信息
 type PoolStats struct {
-	ActiveCount int  // 活跃连接数
-	IdleCount   int  // 空闲连接数
-	IsClosed    bool // 是否已关闭
+	ActiveCount int    // 活跃连接数
+	IdleCount   int    // 空闲连接数
+	IsClosed    bool   // 是否已关闭
+	Dials       uint64 // 建立的连接数
+	Reuses      uint64 // 复用空闲连接的次数
+	Evictions   uint64 // 因不健康或空闲队列已满而关闭的连接数
 }
 
 // Stats 获取连接池统计信息
@@ -318,6 +361,9 @@ func (p *ConnPool) Stats() PoolStats {
 		ActiveCount: p.ActiveCount(),
 		IdleCount:   p.IdleCount(),
 		IsClosed:    p.IsClosed(),
+		Dials:       atomic.LoadUint64(&p.dials),
+		Reuses:      atomic.LoadUint64(&p.reuses),
+		Evictions:   atomic.LoadUint64(&p.evictions),
 	}
 }
 
@@ -338,8 +384,10 @@ func (p *ConnPool) CleanIdleConns() int {
 			// 检查连接健康状态
 			if p.testConn != nil && p.testConn(conn) != nil {
 				// 连接不健康，关闭
+				p.logger.Debug("evicting unhealthy idle connection", "address", p.address)
 				conn.Close()
 				atomic.AddInt32(&p.activeNum, -1)
+				atomic.AddUint64(&p.evictions, 1)
 				cleaned++
 			} else {
 				// 连接健康，放回队列
@@ -350,6 +398,7 @@ func (p *ConnPool) CleanIdleConns() int {
 					// 队列已满，关闭连接
 					conn.Close()
 					atomic.AddInt32(&p.activeNum, -1)
+					atomic.AddUint64(&p.evictions, 1)
 					cleaned++
 				}
 			}
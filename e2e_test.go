@@ -3,6 +3,8 @@ package rerpc
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
 	"sync"
 	"testing"
 	"time"
@@ -54,6 +56,47 @@ func (s *TestService) ErrorMethod(ctx context.Context, args *EchoArgs, reply *Ec
 	return errors.New("intentional error")
 }
 
+type BalanceArgs struct {
+	Amount int `json:"amount"`
+}
+
+type BalanceData struct {
+	Balance int `json:"balance"`
+}
+
+// Withdraw 余额不足时返回带业务错误码的错误
+func (s *TestService) Withdraw(ctx context.Context, args *BalanceArgs, reply *AddReply) error {
+	return fmt.Errorf("withdraw %d: %w", args.Amount, NewError(1001, "insufficient funds", BalanceData{Balance: 10}))
+}
+
+type SleepArgs struct {
+	Duration time.Duration `json:"duration"`
+}
+
+// Sleep 等待指定时间后返回，用于测试并发和超时
+func (s *TestService) Sleep(ctx context.Context, args *SleepArgs, reply *EchoReply) error {
+	select {
+	case <-time.After(args.Duration):
+		reply.Message = "done"
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Subscribe 在返回响应之前向客户端推送一条通知，用于测试服务端推送
+func (s *TestService) Subscribe(ctx context.Context, args *EchoArgs, reply *EchoReply) error {
+	sc, ok := ServerConnFromContext(ctx)
+	if !ok {
+		return errors.New("server push not supported")
+	}
+	if err := sc.Notify("TestService.OnMessage", args); err != nil {
+		return err
+	}
+	reply.Message = "subscribed"
+	return nil
+}
+
 // GetCallCount 获取调用次数
 func (s *TestService) GetCallCount() int {
 	s.mu.Lock()
@@ -666,6 +709,254 @@ func TestE2E_JSONEncoding(t *testing.T) {
 	}
 }
 
+// TestE2E_Notify 测试通知调用
+func TestE2E_Notify(t *testing.T) {
+	// 启动服务器
+	server := NewServer(10)
+	service := &TestService{}
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+
+	go server.Serve("tcp", "localhost:19013")
+	defer server.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// 创建客户端
+	client, err := NewClient(ClientConfig{
+		Network:     "tcp",
+		Address:     "localhost:19013",
+		MaxIdle:     1,
+		MaxActive:   1,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 5; i++ {
+		if err := client.Notify(ctx, "TestService.Add", &AddArgs{A: i, B: i}); err != nil {
+			t.Fatalf("Notify %d failed: %v", i, err)
+		}
+	}
+
+	// 通知不占用 pending
+	if pending := client.Stats().PendingCalls; pending != 0 {
+		t.Errorf("Expected 0 pending calls, got %d", pending)
+	}
+
+	// 同一连接上的后续调用不会读到通知的响应
+	reply := &AddReply{}
+	if err := client.Call(ctx, "TestService.Add", &AddArgs{A: 1, B: 2}, reply); err != nil {
+		t.Fatalf("Call after notify failed: %v", err)
+	}
+	if reply.Result != 3 {
+		t.Errorf("Expected result 3, got %d", reply.Result)
+	}
+
+	// 通知是异步执行的，等待服务端处理完成
+	deadline := time.Now().Add(time.Second)
+	for service.GetCallCount() < 6 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if count := service.GetCallCount(); count != 6 {
+		t.Errorf("Expected 6 calls, got %d", count)
+	}
+}
+
+// failingWriteConn 写入总是失败的连接
+type failingWriteConn struct {
+	net.Conn
+}
+
+func (c failingWriteConn) Write(b []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+// TestE2E_NotifyWriteFailure 测试通知写入失败的连接被丢弃，不会归还连接池
+func TestE2E_NotifyWriteFailure(t *testing.T) {
+	server := NewServer(10)
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	client, err := NewClient(ClientConfig{
+		Network:     "tcp",
+		Address:     listener.Addr().String(),
+		MaxIdle:     1,
+		MaxActive:   1,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Notify(ctx, "TestService.Add", &AddArgs{A: 1, B: 2}); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	// 替换空闲连接，下一次通知写入失败
+	conn := <-client.connPool.idleConns
+	client.connPool.idleConns <- failingWriteConn{conn}
+
+	if err := client.Notify(ctx, "TestService.Add", &AddArgs{A: 1, B: 2}); err == nil {
+		t.Fatal("Expected write error")
+	}
+	if active, idle := client.connPool.ActiveCount(), client.connPool.IdleCount(); active != 0 || idle != 0 {
+		t.Fatalf("Expected broken connection to be discarded, got %d active, %d idle", active, idle)
+	}
+
+	// MaxActive 为 1 时仍然可以建立新连接
+	if err := client.Notify(ctx, "TestService.Add", &AddArgs{A: 1, B: 2}); err != nil {
+		t.Errorf("Notify after write failure failed: %v", err)
+	}
+}
+
+// TestE2E_Multiplex 测试多路复用模式
+func TestE2E_Multiplex(t *testing.T) {
+	// 启动服务器
+	server := NewServer(10)
+	service := &TestService{}
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+
+	go server.Serve("tcp", "localhost:19014")
+	defer server.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// 创建多路复用客户端（单个长连接）
+	client, err := NewClient(ClientConfig{
+		Network:     "tcp",
+		Address:     "localhost:19014",
+		DialTimeout: 5 * time.Second,
+		Multiplex:   true,
+		MuxConns:    1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 慢请求不会阻塞同一连接上的快请求
+	slowDone := make(chan error, 1)
+	go func() {
+		reply := &EchoReply{}
+		slowDone <- client.Call(ctx, "TestService.Sleep", &SleepArgs{Duration: 300 * time.Millisecond}, reply)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	// 并发调用共享同一个连接
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reply := &AddReply{}
+			if err := client.Call(ctx, "TestService.Add", &AddArgs{A: i, B: i}, reply); err != nil {
+				errs <- err
+				return
+			}
+			if reply.Result != i*2 {
+				errs <- fmt.Errorf("call %d: expected %d, got %d", i, i*2, reply.Result)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// 快请求全部完成时慢请求仍在执行，说明响应是乱序返回的
+	select {
+	case err := <-slowDone:
+		t.Fatalf("Slow call finished before fast calls: %v", err)
+	default:
+	}
+
+	if err := <-slowDone; err != nil {
+		t.Fatalf("Slow call failed: %v", err)
+	}
+
+	stats := client.Stats()
+	if stats.PoolStats.ActiveCount != 1 {
+		t.Errorf("Expected 1 active connection, got %d", stats.PoolStats.ActiveCount)
+	}
+	if stats.PendingCalls != 0 {
+		t.Errorf("Expected 0 pending calls, got %d", stats.PendingCalls)
+	}
+}
+
+// TestE2E_AppError 测试业务错误码在客户端保持不变
+func TestE2E_AppError(t *testing.T) {
+	// 启动服务器
+	server := NewServer(10)
+	service := &TestService{}
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+
+	go server.Serve("tcp", "localhost:19015")
+	defer server.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// 创建客户端
+	client, err := NewClient(ClientConfig{
+		Network:     "tcp",
+		Address:     "localhost:19015",
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = client.Call(ctx, "TestService.Withdraw", &BalanceArgs{Amount: 100}, &AddReply{})
+	rpcErr, ok := AsError(err)
+	if !ok {
+		t.Fatalf("Expected *Error, got %T: %v", err, err)
+	}
+	if rpcErr.Code != 1001 || rpcErr.Message != "insufficient funds" {
+		t.Errorf("Expected 1001 insufficient funds, got %d %q", rpcErr.Code, rpcErr.Message)
+	}
+
+	var data BalanceData
+	if err := rpcErr.DecodeData(&data); err != nil {
+		t.Fatalf("DecodeData failed: %v", err)
+	}
+	if data.Balance != 10 {
+		t.Errorf("Expected balance 10, got %d", data.Balance)
+	}
+}
+
 // TestE2E_StressTest 压力测试
 func TestE2E_StressTest(t *testing.T) {
 	if testing.Short() {
@@ -1,5 +1,10 @@
 package rerpc
 
+import (
+	"errors"
+	"time"
+)
+
 // JSON-RPC 2.0 标准错误码
 // 参考规范：https://www.jsonrpc.org/specification#error_object
 const (
@@ -24,6 +29,21 @@ const (
 	ErrCodeInternal = -32603
 )
 
+// ErrCodeRequestCancelled 表示请求已被客户端通过 $/cancelRequest 取消
+// 与 LSP 的 RequestCancelled 错误码一致
+const ErrCodeRequestCancelled = -32800
+
+// ErrCodeUnauthenticated 表示请求未通过服务端的认证（ServerConfig.Authenticator）
+// 位于规范保留给实现自定义服务端错误的 -32000 到 -32099 区间
+const ErrCodeUnauthenticated = -32001
+
+// ErrCodeRateLimited 表示请求超过了服务端的限流（ServerConfig.RateLimiter）
+// data 为 RateLimitData，包含建议的重试等待时间
+const ErrCodeRateLimited = -32002
+
+// ErrCodeServerOverloaded 表示服务端过载，连接或请求被快速拒绝（ServerConfig.OverloadPolicy、MaxConns、MaxRequests）
+const ErrCodeServerOverloaded = -32003
+
 // 标准错误消息
 const (
 	ErrMsgParse          = "Parse error"
@@ -31,6 +51,12 @@ const (
 	ErrMsgMethodNotFound = "Method not found"
 	ErrMsgInvalidParams  = "Invalid params"
 	ErrMsgInternal       = "Internal error"
+
+	ErrMsgRequestCancelled = "Request cancelled"
+	ErrMsgRequestTooLarge  = "Request too large"
+	ErrMsgUnauthenticated  = "Unauthenticated"
+	ErrMsgRateLimited      = "Rate limit exceeded"
+	ErrMsgServerOverloaded = "Server overloaded"
 )
 
 // NewError 创建一个新的 JSON-RPC 错误
@@ -66,3 +92,78 @@ func NewInvalidParamsError(data interface{}) *Error {
 func NewInternalError(data interface{}) *Error {
 	return NewError(ErrCodeInternal, ErrMsgInternal, data)
 }
+
+// NewRequestCancelledError 创建请求已取消错误
+func NewRequestCancelledError() *Error {
+	return NewError(ErrCodeRequestCancelled, ErrMsgRequestCancelled, nil)
+}
+
+// NewRequestTooLargeError 创建请求过大错误
+// 使用 Invalid Request 错误码，data 为服务端允许的最大字节数
+func NewRequestTooLargeError(limit int) *Error {
+	return NewError(ErrCodeInvalidRequest, ErrMsgRequestTooLarge, limit)
+}
+
+// NewUnauthenticatedError 创建认证失败错误，data 为失败原因
+func NewUnauthenticatedError(data interface{}) *Error {
+	return NewError(ErrCodeUnauthenticated, ErrMsgUnauthenticated, data)
+}
+
+// NewRateLimitedError 创建限流错误，retryAfter 为建议的重试等待时间
+func NewRateLimitedError(retryAfter time.Duration) *Error {
+	return NewError(ErrCodeRateLimited, ErrMsgRateLimited, RateLimitData{RetryAfterMs: durationMillis(retryAfter)})
+}
+
+// NewServerOverloadedError 创建服务端过载错误
+func NewServerOverloadedError() *Error {
+	return NewError(ErrCodeServerOverloaded, ErrMsgServerOverloaded, nil)
+}
+
+// CodedError 由业务错误实现，用于指定返回给客户端的错误码
+// 服务方法返回的错误（包括通过 %w 包装的错误）实现该接口时，
+// 错误码和错误消息会原样传递给客户端，而不是转换为 Internal error
+type CodedError interface {
+	error
+	RPCCode() int
+}
+
+// DataError 由业务错误实现，用于附带返回给客户端的 data
+// 通常与 CodedError 一起实现
+type DataError interface {
+	RPCData() interface{}
+}
+
+// toRPCError 将服务方法返回的错误转换为 JSON-RPC 错误
+// 1. 错误链中存在 *Error 时原样返回
+// 2. 错误链中存在 CodedError 时使用其错误码和消息，DataError 提供 data
+// 3. 其他错误转换为 Internal error，错误消息放在 data 中
+func toRPCError(err error) *Error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) && rpcErr != nil {
+		return rpcErr
+	}
+
+	var coded CodedError
+	if errors.As(err, &coded) {
+		var data interface{}
+		var dataErr DataError
+		if errors.As(err, &dataErr) {
+			data = dataErr.RPCData()
+		}
+		return NewError(coded.RPCCode(), coded.Error(), data)
+	}
+
+	return NewInternalError(err.Error())
+}
+
+// AsError 从错误链中提取 JSON-RPC 错误
+// 客户端可以据此按错误码区分服务端返回的业务错误：
+//
+//	if rpcErr, ok := rerpc.AsError(err); ok && rpcErr.Code == 1001 { ... }
+func AsError(err error) (*Error, bool) {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) && rpcErr != nil {
+		return rpcErr, true
+	}
+	return nil, false
+}
@@ -1,117 +1,657 @@
 package rerpc
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// defaultWorkerIdleTimeout 弹性协程池中超过最小数量的工作协程的默认空闲回收时间
+const defaultWorkerIdleTimeout = time.Minute
+
+// spawnRetryInterval 弹性协程池等待队列空闲位置时重试扩容的间隔
+const spawnRetryInterval = time.Millisecond
+
+// ErrPoolFull 表示协程池的任务队列已满，任务没有被提交
+var ErrPoolFull = errors.New("goroutine pool is full")
+
+// Priority 任务优先级
+type Priority int
+
+const (
+	// PriorityNormal 普通优先级（默认）
+	PriorityNormal Priority = iota
+
+	// PriorityHigh 高优先级，排在普通任务之前执行
+	// 适合健康检查、控制面调用等不能被批量流量拖慢的任务
+	PriorityHigh
+)
+
+// String 返回优先级名称
+func (p Priority) String() string {
+	switch p {
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// ParsePriority 解析优先级名称（"normal" 或 "high"）
+func ParsePriority(name string) (Priority, bool) {
+	switch name {
+	case "normal":
+		return PriorityNormal, true
+	case "high":
+		return PriorityHigh, true
+	default:
+		return PriorityNormal, false
+	}
+}
+
+// poolTask 队列中的任务
+type poolTask struct {
+	fn     func() // 任务
+	onShed func() // 任务因队列已满被丢弃时调用（SubmitShedOldest），可以为 nil
+}
+
 // GoroutinePool 协程池，用于限制并发数量和复用 goroutine
 // 性能优化点：
-// 1. 固定数量的 worker goroutine，避免频繁创建销毁
+// 1. 复用 worker goroutine，避免频繁创建销毁
 // 2. 使用 buffered channel 作为任务队列，平滑流量
 // 3. 使用 atomic 标志位管理关闭状态，避免锁竞争
+// 4. 弹性模式下在没有空闲 worker 时扩容到最大数量，空闲的 worker 超时后回收到最小数量
+// 5. 高优先级任务使用独立的队列，worker 优先从中获取任务
 type GoroutinePool struct {
-	workers   int           // 工作协程数量
-	taskQueue chan func()   // 任务队列
-	wg        sync.WaitGroup // 等待所有任务完成
-	once      sync.Once     // 确保只初始化一次
-	closed    int32         // 关闭标志（原子操作）
+	minWorkers  int32          // 最小工作协程数量，启动时创建，不会被回收
+	maxWorkers  int32          // 最大工作协程数量
+	idleTimeout time.Duration  // 超过最小数量的 worker 空闲多久后退出
+	taskQueue   chan poolTask  // 任务队列
+	highQueue   chan poolTask  // 高优先级任务队列
+	highWeight  int            // 连续执行多少个高优先级任务后让出一次给普通任务，0 表示严格优先
+	handoff     chan func()    // 直接交给空闲 worker 的任务（无缓冲）
+	wg          sync.WaitGroup // 等待所有 worker 退出
+	once        sync.Once      // 确保只初始化一次
+	closed      int32          // 关闭标志（原子操作）
+	mu          sync.RWMutex   // 提交任务时持有读锁，Close 持有写锁关闭队列
+	done        chan struct{}  // 关闭时关闭，唤醒等待队列空闲位置的提交者
+	logger      *slog.Logger   // 记录任务 panic 的日志
+	onPanic     PanicHandler   // 任务 panic 时的回调，设置后不再记录日志
+
+	// 统计信息（原子操作）
+	workers   int32  // 当前工作协程数量
+	idle      int32  // 正在等待任务的 worker 数量
+	busy      int32  // 正在执行任务的 worker 数量
+	completed uint64 // 正常完成的任务数量
+	panicked  uint64 // 发生 panic 的任务数量
+	shed      uint64 // 因队列已满被丢弃的任务数量
 }
 
-// NewGoroutinePool 创建一个新的协程池
+// GoroutinePoolConfig 协程池配置
+type GoroutinePoolConfig struct {
+	MinWorkers int // 最小工作协程数量，创建时启动（默认 1）
+	MaxWorkers int // 最大工作协程数量（默认等于 MinWorkers，即固定大小）
+	QueueSize  int // 任务队列大小，0 表示无缓冲；高优先级任务使用同样大小的独立队列
+
+	// IdleTimeout 超过最小数量的工作协程空闲多久后退出（默认 1 分钟）
+	IdleTimeout time.Duration
+
+	// PriorityWeight 两个队列都有任务时，worker 连续执行多少个高优先级任务后执行一个普通任务
+	// 0 表示严格优先：高优先级队列不为空时总是先执行高优先级任务
+	PriorityWeight int
+}
+
+// GoroutinePoolStats 协程池统计信息
+type GoroutinePoolStats struct {
+	Workers    int // 当前工作协程数量
+	MinWorkers int // 最小工作协程数量
+	MaxWorkers int // 最大工作协程数量
+	Idle       int // 正在等待任务的工作协程数量
+	Busy       int // 正在执行任务的工作协程数量
+	Queued     int // 队列中等待执行的任务数量（包括高优先级任务）
+	QueuedHigh int // 高优先级队列中等待执行的任务数量
+
+	Completed uint64 // 正常完成的任务数量
+	Panicked  uint64 // 发生 panic 的任务数量
+	Shed      uint64 // 因队列已满被 SubmitShedOldest 丢弃的任务数量
+}
+
+// NewGoroutinePool 创建一个固定大小的协程池
 // workers: 工作协程数量
 // queueSize: 任务队列大小，0 表示无缓冲
 func NewGoroutinePool(workers int, queueSize int) *GoroutinePool {
-	if workers <= 0 {
-		workers = 1
+	return NewGoroutinePoolWithConfig(GoroutinePoolConfig{
+		MinWorkers: workers,
+		MaxWorkers: workers,
+		QueueSize:  queueSize,
+	})
+}
+
+// NewGoroutinePoolWithConfig 使用指定配置创建协程池
+// MaxWorkers 大于 MinWorkers 时为弹性协程池：
+// 提交任务时没有空闲 worker 则启动新的 worker 直接执行该任务（不超过 MaxWorkers），
+// 超过 MinWorkers 的 worker 空闲 IdleTimeout 后退出
+func NewGoroutinePoolWithConfig(config GoroutinePoolConfig) *GoroutinePool {
+	if config.MinWorkers <= 0 {
+		config.MinWorkers = 1
 	}
-	
+	if config.MaxWorkers < config.MinWorkers {
+		config.MaxWorkers = config.MinWorkers
+	}
+	if config.QueueSize < 0 {
+		config.QueueSize = 0
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaultWorkerIdleTimeout
+	}
+	if config.PriorityWeight < 0 {
+		config.PriorityWeight = 0
+	}
+
 	pool := &GoroutinePool{
-		workers:   workers,
-		taskQueue: make(chan func(), queueSize),
-		closed:    0,
+		minWorkers:  int32(config.MinWorkers),
+		maxWorkers:  int32(config.MaxWorkers),
+		idleTimeout: config.IdleTimeout,
+		taskQueue:   make(chan poolTask, config.QueueSize),
+		highQueue:   make(chan poolTask, config.QueueSize),
+		highWeight:  config.PriorityWeight,
+		handoff:     make(chan func()),
+		done:        make(chan struct{}),
+		closed:      0,
+		logger:      slog.Default(),
 	}
-	
-	// 启动固定数量的 worker goroutine
+
+	// 启动最小数量的 worker goroutine
 	pool.once.Do(func() {
-		for i := 0; i < pool.workers; i++ {
+		atomic.StoreInt32(&pool.workers, pool.minWorkers)
+		for i := int32(0); i < pool.minWorkers; i++ {
 			pool.wg.Add(1)
-			go pool.worker()
+			go pool.worker(nil)
 		}
 	})
-	
+
 	return pool
 }
 
-// worker 工作协程，从任务队列中获取任务并执行
-func (p *GoroutinePool) worker() {
+// elastic 判断是否为弹性协程池
+func (p *GoroutinePool) elastic() bool {
+	return p.maxWorkers > p.minWorkers
+}
+
+// trySpawn 没有空闲 worker 且未达到最大数量时，启动一个新的 worker 直接执行 task
+// task 为 nil 时新的 worker 直接从队列中获取任务
+// 固定大小的协程池总是返回 false
+func (p *GoroutinePool) trySpawn(task func()) bool {
+	if !p.elastic() || atomic.LoadInt32(&p.idle) > 0 || atomic.LoadInt32(&p.closed) == 1 {
+		return false
+	}
+	for {
+		n := atomic.LoadInt32(&p.workers)
+		if n >= p.maxWorkers {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&p.workers, n, n+1) {
+			break
+		}
+	}
+	p.wg.Add(1)
+	go p.worker(task)
+	return true
+}
+
+// ensureWorker 任务入队后，若此时没有空闲 worker（提交前看到的空闲 worker 可能已经超时退出），
+// 弹性协程池启动一个新的 worker 从队列中获取任务
+func (p *GoroutinePool) ensureWorker() {
+	p.trySpawn(nil)
+}
+
+// tryRetire 空闲超时的 worker 尝试退出，worker 数量不低于最小数量
+func (p *GoroutinePool) tryRetire() bool {
+	for {
+		n := atomic.LoadInt32(&p.workers)
+		if n <= p.minWorkers {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&p.workers, n, n-1) {
+			return true
+		}
+	}
+}
+
+// worker 工作协程，先执行 first（可以为 nil），然后从任务队列中获取任务并执行
+func (p *GoroutinePool) worker(first func()) {
 	defer p.wg.Done()
-	
-	// 持续从任务队列中获取任务
-	for task := range p.taskQueue {
-		if task != nil {
-			// 执行任务，捕获 panic 避免 worker 崩溃
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						// 可以在这里记录日志
-						// log.Printf("task panic: %v", r)
-					}
-				}()
-				task()
-			}()
+
+	p.run(first)
+
+	// 弹性协程池中的 worker 空闲超时后尝试退出
+	var idleTimer *time.Timer
+	var idleTimeout <-chan time.Time
+	if p.elastic() {
+		idleTimer = time.NewTimer(p.idleTimeout)
+		defer idleTimer.Stop()
+		idleTimeout = idleTimer.C
+	}
+
+	// 持续从任务队列中获取任务，空闲时也接收直接交付的任务
+	// 两个队列都关闭并取空后退出
+	high, normal := p.highQueue, p.taskQueue
+	streak := 0 // 连续执行的高优先级任务数量
+	for high != nil || normal != nil {
+		// 先按优先级检查排队的任务
+		// 加权模式下连续执行 highWeight 个高优先级任务后先检查普通队列，避免普通任务饿死
+		preferNormal := p.highWeight > 0 && streak >= p.highWeight
+		if task, priority, ok := poll(&high, &normal, preferNormal); ok {
+			streak = nextStreak(streak, priority)
+			p.run(task.fn)
+			continue
+		}
+		if high == nil && normal == nil {
+			break
+		}
+
+		// 没有排队的任务，等待新任务
+		if idleTimer != nil {
+			idleTimer.Reset(p.idleTimeout)
+		}
+
+		atomic.AddInt32(&p.idle, 1)
+		select {
+		case task, ok := <-high:
+			atomic.AddInt32(&p.idle, -1)
+			if !ok {
+				high = nil
+				continue
+			}
+			streak = nextStreak(streak, PriorityHigh)
+			p.run(task.fn)
+		case task, ok := <-normal:
+			atomic.AddInt32(&p.idle, -1)
+			if !ok {
+				normal = nil
+				continue
+			}
+			streak = 0
+			p.run(task.fn)
+		case task := <-p.handoff:
+			atomic.AddInt32(&p.idle, -1)
+			p.run(task)
+		case <-idleTimeout:
+			atomic.AddInt32(&p.idle, -1)
+			if !p.tryRetire() {
+				continue
+			}
+			// 超时与任务入队可能同时发生：提交者看到本 worker 空闲因而没有扩容，
+			// 退出前再检查一次队列，取到任务时恢复为工作协程继续执行，避免任务一直等待忙碌的 worker
+			task, priority, ok := poll(&high, &normal, false)
+			if !ok {
+				return
+			}
+			atomic.AddInt32(&p.workers, 1)
+			streak = nextStreak(streak, priority)
+			p.run(task.fn)
 		}
 	}
+	atomic.AddInt32(&p.workers, -1)
+}
+
+// poll 非阻塞地从队列中获取一个任务，默认先检查高优先级队列
+// 已关闭并取空的队列被置为 nil
+func poll(high, normal *chan poolTask, preferNormal bool) (poolTask, Priority, bool) {
+	lanes := [2]Priority{PriorityHigh, PriorityNormal}
+	if preferNormal {
+		lanes = [2]Priority{PriorityNormal, PriorityHigh}
+	}
+	for _, lane := range lanes {
+		queue := high
+		if lane == PriorityNormal {
+			queue = normal
+		}
+		if *queue == nil {
+			continue
+		}
+		select {
+		case task, ok := <-*queue:
+			if !ok {
+				*queue = nil
+				continue
+			}
+			return task, lane, true
+		default:
+		}
+	}
+	return poolTask{}, PriorityNormal, false
+}
+
+// nextStreak 更新连续执行的高优先级任务数量，执行普通任务后清零
+func nextStreak(streak int, priority Priority) int {
+	if priority == PriorityHigh {
+		return streak + 1
+	}
+	return 0
+}
+
+// queue 返回优先级对应的任务队列
+func (p *GoroutinePool) queue(priority Priority) chan poolTask {
+	if priority == PriorityHigh {
+		return p.highQueue
+	}
+	return p.taskQueue
+}
+
+// run 执行任务，捕获 panic 避免 worker 崩溃
+func (p *GoroutinePool) run(task func()) {
+	if task == nil {
+		return
+	}
+	atomic.AddInt32(&p.busy, 1)
+	defer atomic.AddInt32(&p.busy, -1)
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddUint64(&p.panicked, 1)
+			info := newPanicInfo(r)
+			if p.onPanic != nil {
+				p.onPanic(info)
+				return
+			}
+			p.logger.Error("task panic", "panic", r, "incident_id", info.IncidentID, "stack", string(info.Stack))
+		}
+	}()
+	task()
+	atomic.AddUint64(&p.completed, 1)
+}
+
+// SetLogger 设置记录任务 panic 的日志（默认 slog.Default()）
+// 必须在提交任务之前调用
+func (p *GoroutinePool) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	p.logger = logger
+}
+
+// SetPanicHandler 设置任务 panic 时的回调，设置后 panic 不再记录到日志
+// 回调在发生 panic 的 worker 中调用，worker 随后继续执行其他任务
+// 必须在提交任务之前调用
+func (p *GoroutinePool) SetPanicHandler(handler PanicHandler) {
+	p.onPanic = handler
 }
 
 // Submit 提交任务到协程池
 // 如果协程池已关闭，返回 ErrPoolClosed
 // 如果任务队列已满，会阻塞直到有空闲位置
 func (p *GoroutinePool) Submit(task func()) error {
+	return p.SubmitPriority(PriorityNormal, task)
+}
+
+// SubmitPriority 以指定优先级提交任务，队列已满时阻塞直到有空闲位置
+// 高优先级任务进入独立的队列，worker 空闲时优先执行
+func (p *GoroutinePool) SubmitPriority(priority Priority, task func()) error {
 	if task == nil {
 		return errors.New("task cannot be nil")
 	}
 	
-	// 检查协程池是否已关闭（原子操作，无锁）
+	// 持有读锁期间 Close 不会关闭队列，向队列发送任务是安全的
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	if atomic.LoadInt32(&p.closed) == 1 {
 		return ErrPoolClosed
 	}
-	
+
+	// 弹性协程池：没有空闲 worker 时由新的 worker 直接执行
+	if p.trySpawn(task) {
+		return nil
+	}
+
 	// 提交任务到队列
 	// 注意：这里可能会阻塞，如果队列已满
+	return p.enqueue(context.Background(), p.queue(priority), task)
+}
+
+// TrySubmit 非阻塞地提交任务
+// 队列已满时立即返回 ErrPoolFull，协程池已关闭时返回 ErrPoolClosed
+func (p *GoroutinePool) TrySubmit(task func()) error {
+	return p.TrySubmitPriority(PriorityNormal, task)
+}
+
+// TrySubmitPriority 以指定优先级非阻塞地提交任务
+// 对应优先级的队列已满时立即返回 ErrPoolFull
+func (p *GoroutinePool) TrySubmitPriority(priority Priority, task func()) error {
+	if task == nil {
+		return errors.New("task cannot be nil")
+	}
+	if atomic.LoadInt32(&p.closed) == 1 {
+		return ErrPoolClosed
+	}
+	if !p.trySubmit(priority, task) {
+		return ErrPoolFull
+	}
+	return nil
+}
+
+// SubmitContext 提交任务，队列已满时等待，直到有空闲位置或 ctx 结束
+// ctx 结束时返回 ctx.Err()，任务不会被执行
+func (p *GoroutinePool) SubmitContext(ctx context.Context, task func()) error {
+	if task == nil {
+		return errors.New("task cannot be nil")
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if atomic.LoadInt32(&p.closed) == 1 {
+		return ErrPoolClosed
+	}
+	if p.trySpawn(task) {
+		return nil
+	}
+
+	return p.enqueue(ctx, p.taskQueue, task)
+}
+
+// enqueue 等待 queue 出现空闲位置后提交任务，直到 ctx 结束或协程池关闭
+// 调用者必须持有读锁
+// 弹性协程池中 worker 的空闲计数可能尚未更新，等待期间定期重试扩容，
+// 避免在未达到最大数量时一直等待忙碌的 worker
+func (p *GoroutinePool) enqueue(ctx context.Context, queue chan poolTask, task func()) error {
 	select {
-	case p.taskQueue <- task:
+	case queue <- poolTask{fn: task}:
+		p.ensureWorker()
 		return nil
 	default:
-		// 再次检查是否关闭，避免在关闭过程中阻塞
+	}
+
+	var retry <-chan time.Time
+	if p.elastic() {
+		ticker := time.NewTicker(spawnRetryInterval)
+		defer ticker.Stop()
+		retry = ticker.C
+	}
+
+	for {
+		select {
+		case queue <- poolTask{fn: task}:
+			p.ensureWorker()
+			return nil
+		case <-p.done:
+			// Close 正在等待读锁，放弃等待
+			return ErrPoolClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-retry:
+			if p.trySpawn(task) {
+				return nil
+			}
+		}
+	}
+}
+
+// SubmitShedOldest 提交任务，队列已满时丢弃队列中最旧的任务为新任务腾出位置
+// 被丢弃的任务不会执行，改为在调用者的协程中调用其提交时传入的 onShed（可以为 nil），
+// 调用者可以借此释放任务持有的资源（如关闭连接）
+// 队列长度为 0 时没有可丢弃的任务，等同于 TrySubmit
+// 任务以普通优先级提交，只会丢弃普通队列中的任务
+func (p *GoroutinePool) SubmitShedOldest(task func(), onShed func()) error {
+	if task == nil {
+		return errors.New("task cannot be nil")
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	t := poolTask{fn: task, onShed: onShed}
+	for {
 		if atomic.LoadInt32(&p.closed) == 1 {
 			return ErrPoolClosed
 		}
-		// 阻塞等待队列有空闲位置
-		p.taskQueue <- task
-		return nil
+		if p.trySpawn(task) {
+			return nil
+		}
+
+		select {
+		case p.taskQueue <- t:
+			p.ensureWorker()
+			return nil
+		default:
+		}
+
+		if cap(p.taskQueue) == 0 {
+			return ErrPoolFull
+		}
+
+		// 取出最旧的任务；队列已被 worker 取空时直接重试提交
+		select {
+		case old := <-p.taskQueue:
+			atomic.AddUint64(&p.shed, 1)
+			if old.onShed != nil {
+				old.onShed()
+			}
+		default:
+		}
+	}
+}
+
+// trySubmit 以指定优先级非阻塞地提交任务
+// 队列已满或协程池已关闭时返回 false，任务不会被执行
+func (p *GoroutinePool) trySubmit(priority Priority, task func()) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if atomic.LoadInt32(&p.closed) == 1 {
+		return false
+	}
+	if p.trySpawn(task) {
+		return true
+	}
+
+	select {
+	case p.queue(priority) <- poolTask{fn: task}:
+		p.ensureWorker()
+		return true
+	default:
+		return false
+	}
+}
+
+// tryHandoff 将任务直接交给一个空闲的 worker
+// 只有 worker 正在等待任务（或弹性协程池可以启动新的 worker）时才会成功，任务不会进入队列排队，
+// 因此不会因为 worker 全部被长连接占用而一直得不到执行
+func (p *GoroutinePool) tryHandoff(task func()) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if atomic.LoadInt32(&p.closed) == 1 {
+		return false
+	}
+
+	select {
+	case p.handoff <- task:
+		return true
+	default:
+		return p.trySpawn(task)
+	}
+}
+
+// parallel 并发执行 n 个子任务，fn 接收子任务下标
+// 调用者自身也参与执行（caller-runs），协程池只提供额外的帮手：
+// 即使所有 worker 都被长连接占用，子任务也会由调用者依次完成，不会死锁
+// 帮手只交给空闲的 worker，不进入任务队列：调用者完成全部子任务后仍在排队的帮手会一直占用队列位置，
+// 导致新连接被拒绝（OverloadReject）或阻塞 accept 循环（OverloadBlock）
+func (p *GoroutinePool) parallel(n int, fn func(i int)) {
+	if n <= 0 {
+		return
+	}
+
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(n)
+
+	// 循环领取下一个子任务，直到全部领取完毕
+	run := func() {
+		for {
+			i := int(atomic.AddInt64(&next, 1))
+			if i >= n {
+				return
+			}
+			func() {
+				defer wg.Done()
+				fn(i)
+			}()
+		}
+	}
+
+	// 尝试为剩余子任务申请帮手，没有空闲 worker 时不再等待
+	for i := 1; i < n; i++ {
+		if !p.tryHandoff(run) {
+			break
+		}
 	}
+
+	run()
+	wg.Wait()
 }
 
 // Close 关闭协程池，停止接收新任务，等待所有进行中的任务完成
 // 这是一个优雅关闭的实现：
-// 1. 设置关闭标志，拒绝新任务
-// 2. 关闭任务队列，通知 worker 退出
+// 1. 设置关闭标志，拒绝新任务，唤醒等待队列空闲位置的提交者
+// 2. 等待正在提交的任务完成后关闭任务队列，通知 worker 退出
 // 3. 等待所有 worker 完成当前任务
 func (p *GoroutinePool) Close() {
 	// 使用 CAS 操作设置关闭标志，确保只关闭一次
 	if !atomic.CompareAndSwapInt32(&p.closed, 0, 1) {
 		return // 已经关闭
 	}
-	
-	// 关闭任务队列，worker 会在处理完队列中的任务后退出
+	close(p.done)
+
+	// 提交者在读锁内检查关闭标志并发送任务，取得写锁后不会再有发送，关闭队列是安全的
+	// worker 会在处理完队列中的任务后退出
+	p.mu.Lock()
+	close(p.highQueue)
 	close(p.taskQueue)
-	
+	p.mu.Unlock()
+
 	// 等待所有 worker 完成
 	p.wg.Wait()
 }
 
+// Stats 获取协程池统计信息
+func (p *GoroutinePool) Stats() GoroutinePoolStats {
+	return GoroutinePoolStats{
+		Workers:    int(atomic.LoadInt32(&p.workers)),
+		MinWorkers: int(p.minWorkers),
+		MaxWorkers: int(p.maxWorkers),
+		Idle:       int(atomic.LoadInt32(&p.idle)),
+		Busy:       int(atomic.LoadInt32(&p.busy)),
+		Queued:     len(p.taskQueue) + len(p.highQueue),
+		QueuedHigh: len(p.highQueue),
+
+		Completed: atomic.LoadUint64(&p.completed),
+		Panicked:  atomic.LoadUint64(&p.panicked),
+		Shed:      atomic.LoadUint64(&p.shed),
+	}
+}
+
 // IsClosed 检查协程池是否已关闭
 func (p *GoroutinePool) IsClosed() bool {
 	return atomic.LoadInt32(&p.closed) == 1
@@ -0,0 +1,216 @@
+package rerpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JSON-RPC over HTTP 使用的内容类型
+const contentTypeJSON = "application/json"
+
+// HTTPHandler 返回处理 JSON-RPC over HTTP 的 http.Handler
+// 与 Serve 共享同一个 ServiceRegistry 和 Codec，可以挂载到任意 http.Server 或路由上
+//
+// 状态码约定：
+//   - 200: 返回 JSON-RPC 响应（单个或批量），JSON-RPC 层面的错误包含在响应体中
+//   - 204: 请求全部为通知，没有响应体
+//   - 405: 非 POST 请求
+//   - 413: 请求体超过 SetMaxRequestBytes 设置的大小，响应体为 JSON-RPC 错误
+//   - 415: Content-Type 不是 application/json
+//   - 503: 服务器已关闭
+func (s *Server) HTTPHandler() http.Handler {
+	return &httpHandler{server: s}
+}
+
+// httpHandler JSON-RPC over HTTP 处理器
+type httpHandler struct {
+	server *Server
+}
+
+// ServeHTTP 实现 http.Handler 接口
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.server.IsShutdown() {
+		http.Error(w, "server is shutdown", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	limit := h.server.connOptions().maxMessageSize
+	body, err := readAll(r.Body, limit)
+	if errors.Is(err, ErrMessageTooLarge) {
+		w.Header().Set("Content-Type", contentTypeJSON)
+		w.Header().Set("Connection", "close") // 剩余的请求体不再读取
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write(h.server.encodeErrorResponse(nil, NewRequestTooLargeError(limit)))
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	if h.server.metrics != nil {
+		h.server.metrics.BytesReceived(len(body))
+	}
+
+	// 与 TCP 传输使用相同的处理流程（支持批量请求和通知）
+	// 请求头中的 traceparent 作用于请求体中的所有请求，请求消息中的 traceparent 成员优先
+	ctx := contextWithTraceHeaders(contextWithPeer(r.Context(), requestPeer(r)), r.Header)
+	respData := h.server.processRequest(ctx, body)
+	if respData == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(respData); err == nil && h.server.metrics != nil {
+		h.server.metrics.BytesSent(n)
+	}
+}
+
+// isJSONContentType 判断 Content-Type 是否为 application/json（忽略 charset 等参数）
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == contentTypeJSON
+}
+
+// isHTTPAddress 判断客户端地址是否为 HTTP URL
+func isHTTPAddress(address string) bool {
+	return strings.HasPrefix(address, "http://") || strings.HasPrefix(address, "https://")
+}
+
+// newHTTPClient 根据客户端配置创建 http.Client
+// 连接池参数映射到 http.Transport，连接复用由标准库负责
+func newHTTPClient(config ClientConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: config.DialTimeout}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dialer.DialContext,
+			MaxIdleConnsPerHost: config.MaxIdle,
+			MaxConnsPerHost:     config.MaxActive,
+			IdleConnTimeout:     5 * time.Minute, // 与 ConnPool 的默认空闲超时一致
+			TLSClientConfig:     config.TLSConfig,
+		},
+	}
+}
+
+// doHTTPCall 通过 HTTP POST 执行单次 RPC 调用
+func (c *Client) doHTTPCall(ctx context.Context, call *Call) error {
+	// 生成请求序列号
+	seq := c.nextSeq()
+	call.seq = seq
+
+	// 注册待处理的调用
+	c.mu.Lock()
+	c.pending[seq] = call
+	c.mu.Unlock()
+	defer c.removePending(seq)
+
+	reqData, err := c.encodeCall(ctx, seq, call)
+	if err != nil {
+		return err
+	}
+
+	httpResp, err := c.postHTTP(ctx, reqData)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status: %s", httpResp.Status)
+	}
+
+	respData, err := readAll(httpResp.Body, c.maxResponseBytes)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// 解码响应
+	resp, err := c.codec.DecodeResponse(respData)
+	if err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	defer c.codec.(*JSONCodec).pool.PutResponse(resp)
+
+	// 验证响应 ID
+	if respID, ok := resp.ID.(float64); !ok || uint64(respID) != seq {
+		return fmt.Errorf("response ID mismatch: expected %d, got %v", seq, resp.ID)
+	}
+
+	return c.handleResponse(resp, call)
+}
+
+// notifyHTTP 通过 HTTP POST 发送通知，服务端返回 204 No Content
+func (c *Client) notifyHTTP(ctx context.Context, reqData []byte) error {
+	httpResp, err := c.postHTTP(ctx, reqData)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	// 读完响应体以便复用连接
+	io.Copy(io.Discard, httpResp.Body)
+
+	if httpResp.StatusCode != http.StatusNoContent && httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status: %s", httpResp.Status)
+	}
+	return nil
+}
+
+// postHTTP 发送 JSON-RPC 请求体
+func (c *Client) postHTTP(ctx context.Context, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.httpURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", contentTypeJSON)
+	httpReq.Header.Set("Accept", contentTypeJSON)
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		// context 取消或超时时返回 context 错误，不触发重试
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		// *url.Error 实现了 net.Error，网络错误会被重试
+		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	return httpResp, nil
+}
+
+// pingHTTP 发送 HEAD 请求验证服务端可达
+// 任何 HTTP 响应（包括 405）都说明服务端可达
+func (c *Client) pingHTTP() error {
+	httpReq, err := http.NewRequest(http.MethodHead, c.httpURL, nil)
+	if err != nil {
+		return err
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoConnection, err)
+	}
+	httpResp.Body.Close()
+	return nil
+}
@@ -0,0 +1,150 @@
+package rerpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHTTPHandler_StatusCodes 测试 HTTP 处理器的状态码
+func TestHTTPHandler_StatusCodes(t *testing.T) {
+	server := newTestServer(t)
+	ts := httptest.NewServer(server.HTTPHandler())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:        "单个请求",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2},"id":1}`,
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"jsonrpc":"2.0","result":{"result":3},"id":1}`,
+		},
+		{
+			name:        "批量请求",
+			method:      http.MethodPost,
+			contentType: "application/json; charset=utf-8",
+			body:        `[{"jsonrpc":"2.0","method":"TestService.Add","params":[1,2],"id":1}]`,
+			wantStatus:  http.StatusOK,
+			wantBody:    `[{"jsonrpc":"2.0","result":{"result":3},"id":1}]`,
+		},
+		{
+			name:        "通知",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2}}`,
+			wantStatus:  http.StatusNoContent,
+		},
+		{
+			name:        "JSON-RPC 错误",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"jsonrpc":"2.0","method":"TestService.Add"`,
+			wantStatus:  http.StatusOK,
+			wantBody:    `"code":-32700`,
+		},
+		{
+			name:       "非 POST 请求",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:        "错误的 Content-Type",
+			method:      http.MethodPost,
+			contentType: "text/plain",
+			body:        `{}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("NewRequest failed: %v", err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			if tt.wantBody != "" {
+				if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Expected Content-Type application/json, got %q", ct)
+				}
+				if !strings.Contains(string(body), tt.wantBody) {
+					t.Errorf("Expected body to contain %s, got %s", tt.wantBody, body)
+				}
+			}
+		})
+	}
+}
+
+// TestHTTPClient 测试客户端通过 HTTP 传输调用
+func TestHTTPClient(t *testing.T) {
+	server := NewServer(4)
+	service := &TestService{}
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	ts := httptest.NewServer(server.HTTPHandler())
+	defer ts.Close()
+
+	client, err := NewClient(ClientConfig{Address: ts.URL})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+
+	reply := &AddReply{}
+	if err := client.Call(ctx, "TestService.Add", &AddArgs{A: 10, B: 20}, reply); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if reply.Result != 30 {
+		t.Errorf("Expected result 30, got %d", reply.Result)
+	}
+
+	// 业务错误通过 HTTP 传输保持不变
+	err = client.Call(ctx, "TestService.Withdraw", &BalanceArgs{Amount: 100}, &AddReply{})
+	if rpcErr, ok := AsError(err); !ok || rpcErr.Code != 1001 {
+		t.Errorf("Expected error code 1001, got %v", err)
+	}
+
+	if err := client.Notify(ctx, "TestService.Add", &AddArgs{A: 1, B: 1}); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if count := service.GetCallCount(); count != 2 {
+		t.Errorf("Expected 2 calls, got %d", count)
+	}
+}
@@ -0,0 +1,39 @@
+package rerpc
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// logRequest 记录一条访问日志
+// 成功的请求为 Info 级别，失败的请求为 Warn 级别并附带错误码
+func (s *Server) logRequest(ctx context.Context, req *Request, duration time.Duration, rpcErr *Error) {
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.Any("id", req.ID),
+		slog.Duration("duration", duration),
+	}
+	if peer, ok := PeerFromContext(ctx); ok && peer.Addr != nil {
+		attrs = append(attrs, slog.String("remote_addr", peer.Addr.String()))
+	}
+
+	level := slog.LevelInfo
+	if rpcErr != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.Int("error_code", rpcErr.Code), slog.String("error", rpcErr.Message))
+	}
+
+	s.logger.LogAttrs(ctx, level, "rpc request", attrs...)
+}
+
+// logPanic 记录服务方法中的 panic，客户端只收到事件 ID 时用于从日志中找到 panic 详情
+func (s *Server) logPanic(info PanicInfo) {
+	s.logger.Error("handler panic",
+		"method", info.Method,
+		"id", info.RequestID,
+		"incident_id", info.IncidentID,
+		"panic", info.Value,
+		"stack", string(info.Stack),
+	)
+}
@@ -0,0 +1,111 @@
+package rerpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer 并发安全的日志缓冲
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records 将 JSON 日志解析为记录列表
+func (b *syncBuffer) records(t *testing.T) []map[string]interface{} {
+	t.Helper()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid log record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// TestServer_AccessLog 测试访问日志的结构化字段
+func TestServer_AccessLog(t *testing.T) {
+	logs := &syncBuffer{}
+	server := NewServerWithConfig(ServerConfig{
+		Workers:   4,
+		Logger:    slog.New(slog.NewJSONHandler(logs, nil)),
+		AccessLog: true,
+	})
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	ctx := contextWithPeer(context.Background(), &Peer{Addr: pipeAddr{}})
+	server.processRequest(ctx, []byte(`{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2},"id":1}`))
+	server.processRequest(ctx, []byte(`{"jsonrpc":"2.0","method":"Missing.Method","id":"abc"}`))
+
+	records := logs.records(t)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 access log records, got %d", len(records))
+	}
+
+	ok := records[0]
+	if ok["level"] != "INFO" || ok["msg"] != "rpc request" || ok["method"] != "TestService.Add" ||
+		ok["id"] != float64(1) || ok["remote_addr"] != "pipe" {
+		t.Errorf("Unexpected record: %v", ok)
+	}
+	if _, has := ok["duration"]; !has {
+		t.Errorf("Expected duration in record: %v", ok)
+	}
+	if _, has := ok["error_code"]; has {
+		t.Errorf("Unexpected error_code in successful record: %v", ok)
+	}
+
+	failed := records[1]
+	if failed["level"] != "WARN" || failed["id"] != "abc" || failed["error_code"] != float64(ErrCodeMethodNotFound) {
+		t.Errorf("Unexpected record: %v", failed)
+	}
+}
+
+// TestGoroutinePool_PanicLogged 测试任务 panic 被记录到日志，worker 继续工作
+func TestGoroutinePool_PanicLogged(t *testing.T) {
+	logs := &syncBuffer{}
+	pool := NewGoroutinePool(1, 1)
+	pool.SetLogger(slog.New(slog.NewJSONHandler(logs, nil)))
+	defer pool.Close()
+
+	pool.Submit(func() { panic("boom") })
+
+	done := make(chan struct{})
+	pool.Submit(func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Worker did not survive panic")
+	}
+
+	records := logs.records(t)
+	if len(records) != 1 || records[0]["msg"] != "task panic" || records[0]["panic"] != "boom" {
+		t.Fatalf("Unexpected records: %v", records)
+	}
+	if stack, _ := records[0]["stack"].(string); !strings.Contains(stack, "goroutine") {
+		t.Errorf("Expected stack trace, got %q", stack)
+	}
+}
@@ -0,0 +1,125 @@
+package rerpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTrailerUnavailable ctx 不属于一个需要响应的服务端请求（例如通知），无法设置响应元数据
+var ErrTrailerUnavailable = errors.New("trailer not available")
+
+// Metadata 随调用传递的键值对，如认证令牌、租户 ID、请求 ID、语言
+// 请求元数据放在请求的扩展成员 metadata 中，响应元数据（trailer）放在响应的扩展成员 trailer 中
+// 键区分大小写，建议统一使用小写
+type Metadata map[string]string
+
+// Clone 返回元数据的副本
+func (md Metadata) Clone() Metadata {
+	if md == nil {
+		return nil
+	}
+	out := make(Metadata, len(md))
+	for k, v := range md {
+		out[k] = v
+	}
+	return out
+}
+
+// merge 返回合并后的新元数据，other 中的键覆盖 md 中的同名键
+func (md Metadata) merge(other Metadata) Metadata {
+	out := make(Metadata, len(md)+len(other))
+	for k, v := range md {
+		out[k] = v
+	}
+	for k, v := range other {
+		out[k] = v
+	}
+	return out
+}
+
+// outgoingMetadataKey context 中保存客户端待发送元数据的键
+type outgoingMetadataKey struct{}
+
+// incomingMetadataKey context 中保存服务端收到的元数据的键
+type incomingMetadataKey struct{}
+
+// trailerKey context 中保存 *trailer 的键
+type trailerKey struct{}
+
+// trailerDestKey context 中保存客户端接收响应元数据的 *Metadata 的键
+type trailerDestKey struct{}
+
+// WithMetadata 返回携带请求元数据的 ctx，客户端使用该 ctx 发起的调用会把元数据发送给服务端
+// 多次调用时合并，同名键以后设置的为准；md 会被复制，之后修改 md 不影响 ctx
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	existing, _ := ctx.Value(outgoingMetadataKey{}).(Metadata)
+	return context.WithValue(ctx, outgoingMetadataKey{}, existing.merge(md))
+}
+
+// outgoingMetadata 获取客户端待发送的元数据
+func outgoingMetadata(ctx context.Context) Metadata {
+	md, _ := ctx.Value(outgoingMetadataKey{}).(Metadata)
+	return md
+}
+
+// MetadataFromContext 获取客户端随请求发送的元数据，在服务方法和服务端拦截器中使用
+// 收到的元数据不会自动传递给服务方法中发起的调用，需要时使用 WithMetadata 显式设置
+func MetadataFromContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(incomingMetadataKey{}).(Metadata)
+	return md, ok
+}
+
+// contextWithIncomingMetadata 将请求中的元数据保存到 ctx
+func contextWithIncomingMetadata(ctx context.Context, md Metadata) context.Context {
+	if md == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, incomingMetadataKey{}, md)
+}
+
+// trailer 服务端一个请求的响应元数据
+// 服务方法可能在多个协程中设置，使用互斥锁保护
+type trailer struct {
+	mu sync.Mutex
+	md Metadata
+}
+
+// contextWithTrailer 为需要响应的请求创建响应元数据
+func contextWithTrailer(ctx context.Context) (context.Context, *trailer) {
+	t := &trailer{}
+	return context.WithValue(ctx, trailerKey{}, t), t
+}
+
+// metadata 返回已设置的响应元数据
+func (t *trailer) metadata() Metadata {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.md
+}
+
+// SetTrailer 设置响应元数据，随响应返回给客户端，在服务方法和服务端拦截器中使用
+// 多次调用时合并，同名键以后设置的为准；服务方法返回后设置的元数据不会被发送
+// ctx 不属于需要响应的请求（例如通知）时返回 ErrTrailerUnavailable
+func SetTrailer(ctx context.Context, md Metadata) error {
+	t, ok := ctx.Value(trailerKey{}).(*trailer)
+	if !ok {
+		return ErrTrailerUnavailable
+	}
+	t.mu.Lock()
+	t.md = t.md.merge(md)
+	t.mu.Unlock()
+	return nil
+}
+
+// WithTrailer 返回接收响应元数据的 ctx，调用完成后服务端设置的元数据保存到 *md
+// 调用返回错误响应时也会保存；Batch 中的调用共享同一个 ctx，不应使用
+func WithTrailer(ctx context.Context, md *Metadata) context.Context {
+	return context.WithValue(ctx, trailerDestKey{}, md)
+}
+
+// trailerDest 获取接收响应元数据的 *Metadata
+func trailerDest(ctx context.Context) *Metadata {
+	md, _ := ctx.Value(trailerDestKey{}).(*Metadata)
+	return md
+}
@@ -0,0 +1,162 @@
+package rerpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// MetadataService 读取请求元数据并设置响应元数据
+type MetadataService struct {
+	mu         sync.Mutex
+	trailerErr error
+}
+
+func (s *MetadataService) Tenant(ctx context.Context, args *EchoArgs, reply *EchoReply) error {
+	md, _ := MetadataFromContext(ctx)
+	if err := SetTrailer(ctx, Metadata{"tenant": md["tenant"], "request-id": md["request-id"]}); err != nil {
+		return err
+	}
+	if args.Message == "fail" {
+		return errors.New("tenant failed")
+	}
+	reply.Message = md["tenant"]
+	return nil
+}
+
+func (s *MetadataService) Notify(ctx context.Context, args *EchoArgs, reply *EchoReply) error {
+	s.mu.Lock()
+	s.trailerErr = SetTrailer(ctx, Metadata{"k": "v"})
+	s.mu.Unlock()
+	return nil
+}
+
+// TestWithMetadata 测试元数据的合并
+func TestWithMetadata(t *testing.T) {
+	md := Metadata{"tenant": "a", "locale": "zh"}
+	ctx := WithMetadata(context.Background(), md)
+	ctx = WithMetadata(ctx, Metadata{"tenant": "b"})
+	md["locale"] = "en" // 修改原始元数据不影响 ctx
+
+	got := outgoingMetadata(ctx)
+	if got["tenant"] != "b" || got["locale"] != "zh" || len(got) != 2 {
+		t.Errorf("Unexpected metadata: %v", got)
+	}
+
+	// 客户端设置的元数据不是服务端收到的元数据
+	if _, ok := MetadataFromContext(ctx); ok {
+		t.Error("Expected no incoming metadata")
+	}
+	if err := SetTrailer(ctx, Metadata{"k": "v"}); !errors.Is(err, ErrTrailerUnavailable) {
+		t.Errorf("Expected ErrTrailerUnavailable, got %v", err)
+	}
+}
+
+// TestMetadata 测试请求元数据和响应元数据在各传输上的传递
+func TestMetadata(t *testing.T) {
+	server := NewServer(4)
+	service := &MetadataService{}
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	// 服务端拦截器同样可以读取元数据
+	var seen sync.Map
+	server.Use(func(next Handler) Handler {
+		return func(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+			if md, ok := MetadataFromContext(ctx); ok {
+				seen.Store(md["request-id"], method)
+			}
+			return next(ctx, method, params)
+		}
+	})
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	ts := httptest.NewServer(server.HTTPHandler())
+	defer ts.Close()
+
+	transports := []struct {
+		name   string
+		config ClientConfig
+	}{
+		{"Pool", ClientConfig{Address: listener.Addr().String()}},
+		{"Multiplex", ClientConfig{Address: listener.Addr().String(), Multiplex: true}},
+		{"HTTP", ClientConfig{Address: ts.URL}},
+	}
+
+	for _, tt := range transports {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(tt.config)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			ctx = WithMetadata(ctx, Metadata{"tenant": "acme", "request-id": tt.name})
+
+			var trailer Metadata
+			var reply EchoReply
+			if err := client.Call(WithTrailer(ctx, &trailer), "MetadataService.Tenant", &EchoArgs{}, &reply); err != nil {
+				t.Fatalf("Call failed: %v", err)
+			}
+			if reply.Message != "acme" {
+				t.Errorf("Expected tenant acme, got %q", reply.Message)
+			}
+			if trailer["tenant"] != "acme" || trailer["request-id"] != tt.name {
+				t.Errorf("Unexpected trailer: %v", trailer)
+			}
+			if method, ok := seen.Load(tt.name); !ok || method != "MetadataService.Tenant" {
+				t.Errorf("Expected middleware to see metadata, got %v", method)
+			}
+
+			// 错误响应同样携带响应元数据
+			trailer = nil
+			if err := client.Call(WithTrailer(ctx, &trailer), "MetadataService.Tenant", &EchoArgs{Message: "fail"}, &reply); err == nil {
+				t.Fatal("Expected error")
+			}
+			if trailer["tenant"] != "acme" {
+				t.Errorf("Expected trailer on error response, got %v", trailer)
+			}
+		})
+	}
+
+	// 通知没有响应，无法设置响应元数据
+	client, err := NewClient(ClientConfig{Address: listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Notify(context.Background(), "MetadataService.Notify", &EchoArgs{}); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		service.mu.Lock()
+		err := service.trailerErr
+		service.mu.Unlock()
+		if err != nil {
+			if !errors.Is(err, ErrTrailerUnavailable) {
+				t.Errorf("Expected ErrTrailerUnavailable, got %v", err)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Notification was not handled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
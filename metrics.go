@@ -0,0 +1,487 @@
+package rerpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics 指标记录接口
+// 服务端（ServerConfig.Metrics）和客户端（ClientConfig.Metrics）在处理过程中调用，实现必须是并发安全的
+// 内置实现 PrometheusMetrics 以 Prometheus 文本格式导出，也可以实现该接口适配其他监控系统
+type Metrics interface {
+	// ServerRequestStarted 服务端开始处理一个请求（包括通知和批量请求中的每一项）
+	// 未注册的方法统一使用 "unknown"，避免任意方法名导致标签无限增长
+	ServerRequestStarted(method string)
+
+	// ServerRequestFinished 服务端请求处理完成，err 为 nil 表示成功
+	ServerRequestFinished(method string, err error, duration time.Duration)
+
+	// ClientCallFinished 客户端一次逻辑调用（Call、Go、Batch 中的每一项、Notify，包括重试）完成
+	ClientCallFinished(method string, err error, duration time.Duration)
+
+	// ConnOpened 服务端接受了一个连接（TCP 或 WebSocket）
+	ConnOpened()
+
+	// ConnClosed 服务端连接关闭
+	ConnClosed()
+
+	// AcceptError 服务端接受连接失败
+	AcceptError()
+
+	// BytesReceived 服务端收到一条消息（所有传输），n 为消息字节数
+	BytesReceived(n int)
+
+	// BytesSent 服务端发出一条消息（响应或推送的通知），n 为消息字节数
+	BytesSent(n int)
+}
+
+// GoroutinePoolCollector 由需要采集协程池状态的 Metrics 实现
+// 服务端创建时自动以 "server" 为名称注册自身的协程池
+type GoroutinePoolCollector interface {
+	RegisterGoroutinePool(name string, pool *GoroutinePool)
+}
+
+// ConnPoolCollector 由需要采集连接池状态的 Metrics 实现
+// 客户端创建时自动以服务端地址为名称注册自身的连接池，同一地址可能注册多个连接池
+type ConnPoolCollector interface {
+	RegisterConnPool(name string, pool *ConnPool)
+}
+
+// unknownMethod 未注册方法的指标标签
+const unknownMethod = "unknown"
+
+// metricsMethod 返回请求在指标中使用的方法名
+func (s *Server) metricsMethod(method string) string {
+	serviceName, methodName, err := parseMethod(method)
+	if err != nil || !s.registry.hasMethod(serviceName, methodName) {
+		return unknownMethod
+	}
+	return method
+}
+
+// metricsMiddleware 记录客户端调用指标的拦截器，位于所有用户拦截器的外层
+func metricsMiddleware(metrics Metrics) ClientMiddleware {
+	return func(next Invoker) Invoker {
+		return func(ctx context.Context, method string, args, reply interface{}) error {
+			start := time.Now()
+			err := next(ctx, method, args, reply)
+			metrics.ClientCallFinished(method, err, time.Since(start))
+			return err
+		}
+	}
+}
+
+// DefaultBuckets 请求耗时直方图的默认分桶（秒），与 Prometheus 客户端库一致
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics 内置的 Metrics 实现，以 Prometheus 文本格式导出，不依赖第三方库
+// 导出的指标：
+//   - rerpc_server_requests_total / rerpc_server_request_duration_seconds: 按 method 和 code 统计的请求数和耗时
+//   - rerpc_server_requests_in_flight: 按 method 统计的正在处理的请求数
+//   - rerpc_server_connections / rerpc_server_accept_errors_total: 当前连接数和接受连接失败次数
+//   - rerpc_server_received_bytes_total / rerpc_server_sent_bytes_total: 收发字节数
+//   - rerpc_client_requests_total / rerpc_client_request_duration_seconds: 客户端调用数和耗时
+//   - rerpc_goroutine_pool_*: 协程池的工作协程数、空闲和忙碌协程数、队列长度，以及完成、panic 和丢弃的任务数
+//   - rerpc_conn_pool_*: 连接池的活跃、空闲连接数以及建立、复用、淘汰的连接数
+//
+// code 标签：成功为 "ok"，JSON-RPC 错误为错误码，ctx 超时和取消分别为 "deadline_exceeded" 和 "canceled"，其他错误为 "error"
+type PrometheusMetrics struct {
+	buckets []float64 // 直方图分桶上界（升序）
+
+	mu             sync.Mutex
+	serverRequests map[metricKey]*histogram
+	clientCalls    map[metricKey]*histogram
+	inFlight       map[string]int64
+	goroutinePools map[string]*GoroutinePool
+	connPools      map[string][]*ConnPool // 同一服务端地址的多个客户端的连接池，指标按地址汇总
+	closedPools    map[string]PoolStats   // 已关闭并移除的连接池累计的计数器，保证计数器不减少
+
+	// 计数器（原子操作）
+	connections   int64
+	acceptErrors  uint64
+	bytesReceived uint64
+	bytesSent     uint64
+}
+
+// metricKey 请求指标的标签
+type metricKey struct {
+	method string
+	code   string
+}
+
+// histogram 直方图
+type histogram struct {
+	counts []uint64 // 每个分桶的计数（不累加），最后一个为 +Inf
+	sum    float64
+	count  uint64
+}
+
+// copyHistograms 复制直方图，用于在锁外写出
+func copyHistograms(histograms map[metricKey]*histogram) map[metricKey]*histogram {
+	copied := make(map[metricKey]*histogram, len(histograms))
+	for key, h := range histograms {
+		copied[key] = &histogram{
+			counts: append([]uint64(nil), h.counts...),
+			sum:    h.sum,
+			count:  h.count,
+		}
+	}
+	return copied
+}
+
+// observe 记录一次观测值
+func (h *histogram) observe(buckets []float64, v float64) {
+	i := sort.SearchFloat64s(buckets, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// NewPrometheusMetrics 创建 Prometheus 指标
+// buckets 为耗时直方图的分桶上界（秒），为空时使用 DefaultBuckets
+func NewPrometheusMetrics(buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &PrometheusMetrics{
+		buckets:        buckets,
+		serverRequests: make(map[metricKey]*histogram),
+		clientCalls:    make(map[metricKey]*histogram),
+		inFlight:       make(map[string]int64),
+		goroutinePools: make(map[string]*GoroutinePool),
+		connPools:      make(map[string][]*ConnPool),
+		closedPools:    make(map[string]PoolStats),
+	}
+}
+
+// metricsCode 将错误转换为 code 标签
+func metricsCode(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	if rpcErr, ok := AsError(err); ok {
+		return strconv.Itoa(rpcErr.Code)
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// observe 在 histograms 中记录一次请求
+func (m *PrometheusMetrics) observe(histograms map[metricKey]*histogram, method string, err error, duration time.Duration) {
+	key := metricKey{method: method, code: metricsCode(err)}
+
+	h, ok := histograms[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets)+1)}
+		histograms[key] = h
+	}
+	h.observe(m.buckets, duration.Seconds())
+}
+
+// ServerRequestStarted 实现 Metrics 接口
+func (m *PrometheusMetrics) ServerRequestStarted(method string) {
+	m.mu.Lock()
+	m.inFlight[method]++
+	m.mu.Unlock()
+}
+
+// ServerRequestFinished 实现 Metrics 接口
+func (m *PrometheusMetrics) ServerRequestFinished(method string, err error, duration time.Duration) {
+	m.mu.Lock()
+	m.inFlight[method]--
+	m.observe(m.serverRequests, method, err, duration)
+	m.mu.Unlock()
+}
+
+// ClientCallFinished 实现 Metrics 接口
+func (m *PrometheusMetrics) ClientCallFinished(method string, err error, duration time.Duration) {
+	m.mu.Lock()
+	m.observe(m.clientCalls, method, err, duration)
+	m.mu.Unlock()
+}
+
+// ConnOpened 实现 Metrics 接口
+func (m *PrometheusMetrics) ConnOpened() {
+	atomic.AddInt64(&m.connections, 1)
+}
+
+// ConnClosed 实现 Metrics 接口
+func (m *PrometheusMetrics) ConnClosed() {
+	atomic.AddInt64(&m.connections, -1)
+}
+
+// AcceptError 实现 Metrics 接口
+func (m *PrometheusMetrics) AcceptError() {
+	atomic.AddUint64(&m.acceptErrors, 1)
+}
+
+// BytesReceived 实现 Metrics 接口
+func (m *PrometheusMetrics) BytesReceived(n int) {
+	atomic.AddUint64(&m.bytesReceived, uint64(n))
+}
+
+// BytesSent 实现 Metrics 接口
+func (m *PrometheusMetrics) BytesSent(n int) {
+	atomic.AddUint64(&m.bytesSent, uint64(n))
+}
+
+// RegisterGoroutinePool 注册需要采集的协程池，同名的协程池会被替换
+func (m *PrometheusMetrics) RegisterGoroutinePool(name string, pool *GoroutinePool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.goroutinePools[name] = pool
+}
+
+// RegisterConnPool 注册需要采集的连接池
+// 同名的多个连接池（如连接同一地址的多个客户端）的指标相加；连接池关闭后在下一次导出时移除，
+// 其计数器累计到同名的指标中
+func (m *PrometheusMetrics) RegisterConnPool(name string, pool *ConnPool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.connPools[name] {
+		if p == pool {
+			return
+		}
+	}
+	m.connPools[name] = append(m.connPools[name], pool)
+}
+
+// collectConnPools 按名称汇总连接池的统计信息，调用者必须持有 m.mu
+// 移除已关闭的连接池，只保留其计数器
+func (m *PrometheusMetrics) collectConnPools() map[string]PoolStats {
+	result := make(map[string]PoolStats, len(m.connPools))
+	for name, pools := range m.connPools {
+		closed := m.closedPools[name]
+		total := closed
+		live := pools[:0]
+		for _, pool := range pools {
+			stats := pool.Stats()
+			if stats.IsClosed {
+				closed.Dials += stats.Dials
+				closed.Reuses += stats.Reuses
+				closed.Evictions += stats.Evictions
+			} else {
+				live = append(live, pool)
+				total.ActiveCount += stats.ActiveCount
+				total.IdleCount += stats.IdleCount
+			}
+			total.Dials += stats.Dials
+			total.Reuses += stats.Reuses
+			total.Evictions += stats.Evictions
+		}
+		for i := len(live); i < len(pools); i++ {
+			pools[i] = nil
+		}
+		m.connPools[name] = live
+		m.closedPools[name] = closed
+		result[name] = total
+	}
+	return result
+}
+
+// Handler 返回以 Prometheus 文本格式输出指标的 http.Handler，通常挂载到 /metrics
+func (m *PrometheusMetrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.Export(w)
+	})
+}
+
+// Export 以 Prometheus 文本格式写出所有指标
+func (m *PrometheusMetrics) Export(w io.Writer) error {
+	pw := &promWriter{w: bufio.NewWriter(w)}
+
+	// 只在锁内复制数据，格式化和写出在锁外进行：
+	// 记录指标的每个 RPC 都需要同一把锁，慢速的抓取方不能阻塞请求处理
+	m.mu.Lock()
+	serverRequests := copyHistograms(m.serverRequests)
+	clientCalls := copyHistograms(m.clientCalls)
+	inFlight := make(map[string]int64, len(m.inFlight))
+	for method, n := range m.inFlight {
+		inFlight[method] = n
+	}
+	goroutinePools := make(map[string]*GoroutinePool, len(m.goroutinePools))
+	for name, pool := range m.goroutinePools {
+		goroutinePools[name] = pool
+	}
+	connPools := m.collectConnPools()
+	m.mu.Unlock()
+
+	pw.histograms("rerpc_server_request", "服务端处理的请求", m.buckets, serverRequests)
+	pw.help("rerpc_server_requests_in_flight", "gauge", "服务端正在处理的请求数")
+	for _, method := range sortedKeys(inFlight) {
+		pw.sample("rerpc_server_requests_in_flight", labels("method", method), float64(inFlight[method]))
+	}
+	pw.histograms("rerpc_client_request", "客户端发起的调用", m.buckets, clientCalls)
+
+	pw.help("rerpc_server_connections", "gauge", "服务端当前连接数")
+	pw.sample("rerpc_server_connections", "", float64(atomic.LoadInt64(&m.connections)))
+	pw.help("rerpc_server_accept_errors_total", "counter", "服务端接受连接失败次数")
+	pw.sample("rerpc_server_accept_errors_total", "", float64(atomic.LoadUint64(&m.acceptErrors)))
+	pw.help("rerpc_server_received_bytes_total", "counter", "服务端收到的消息字节数")
+	pw.sample("rerpc_server_received_bytes_total", "", float64(atomic.LoadUint64(&m.bytesReceived)))
+	pw.help("rerpc_server_sent_bytes_total", "counter", "服务端发出的消息字节数")
+	pw.sample("rerpc_server_sent_bytes_total", "", float64(atomic.LoadUint64(&m.bytesSent)))
+
+	if len(goroutinePools) > 0 {
+		names := sortedKeys(goroutinePools)
+		stats := make([]GoroutinePoolStats, len(names))
+		for i, name := range names {
+			stats[i] = goroutinePools[name].Stats()
+		}
+		metrics := []struct {
+			name, kind, help string
+			value            func(GoroutinePoolStats) float64
+		}{
+			{"rerpc_goroutine_pool_workers", "gauge", "协程池工作协程数", func(s GoroutinePoolStats) float64 { return float64(s.Workers) }},
+			{"rerpc_goroutine_pool_idle_workers", "gauge", "协程池等待任务的工作协程数", func(s GoroutinePoolStats) float64 { return float64(s.Idle) }},
+			{"rerpc_goroutine_pool_busy_workers", "gauge", "协程池正在执行任务的工作协程数", func(s GoroutinePoolStats) float64 { return float64(s.Busy) }},
+			{"rerpc_goroutine_pool_queue_depth", "gauge", "协程池队列中等待的任务数", func(s GoroutinePoolStats) float64 { return float64(s.Queued) }},
+			{"rerpc_goroutine_pool_completed_total", "counter", "协程池正常完成的任务数", func(s GoroutinePoolStats) float64 { return float64(s.Completed) }},
+			{"rerpc_goroutine_pool_panicked_total", "counter", "协程池发生 panic 的任务数", func(s GoroutinePoolStats) float64 { return float64(s.Panicked) }},
+			{"rerpc_goroutine_pool_shed_total", "counter", "协程池因队列已满丢弃的任务数", func(s GoroutinePoolStats) float64 { return float64(s.Shed) }},
+		}
+		for _, mt := range metrics {
+			pw.help(mt.name, mt.kind, mt.help)
+			for i, name := range names {
+				pw.sample(mt.name, labels("pool", name), mt.value(stats[i]))
+			}
+		}
+	}
+
+	if len(connPools) > 0 {
+		names := sortedKeys(connPools)
+		stats := make([]PoolStats, len(names))
+		for i, name := range names {
+			stats[i] = connPools[name]
+		}
+		metrics := []struct {
+			name, kind, help string
+			value            func(PoolStats) float64
+		}{
+			{"rerpc_conn_pool_active_connections", "gauge", "连接池活跃连接数", func(s PoolStats) float64 { return float64(s.ActiveCount) }},
+			{"rerpc_conn_pool_idle_connections", "gauge", "连接池空闲连接数", func(s PoolStats) float64 { return float64(s.IdleCount) }},
+			{"rerpc_conn_pool_dials_total", "counter", "连接池建立的连接数", func(s PoolStats) float64 { return float64(s.Dials) }},
+			{"rerpc_conn_pool_reuses_total", "counter", "连接池复用空闲连接的次数", func(s PoolStats) float64 { return float64(s.Reuses) }},
+			{"rerpc_conn_pool_evictions_total", "counter", "连接池淘汰的连接数", func(s PoolStats) float64 { return float64(s.Evictions) }},
+		}
+		for _, mt := range metrics {
+			pw.help(mt.name, mt.kind, mt.help)
+			for i, name := range names {
+				pw.sample(mt.name, labels("pool", name), mt.value(stats[i]))
+			}
+		}
+	}
+
+	if pw.err != nil {
+		return pw.err
+	}
+	return pw.w.Flush()
+}
+
+// promWriter Prometheus 文本格式写入器，记录第一个写入错误
+type promWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// help 写出指标的 HELP 和 TYPE 行
+func (pw *promWriter) help(name, kind, help string) {
+	if pw.err != nil {
+		return
+	}
+	_, pw.err = fmt.Fprintf(pw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample 写出一个样本，labels 为已格式化的标签（不含花括号）
+func (pw *promWriter) sample(name, labels string, value float64) {
+	if pw.err != nil {
+		return
+	}
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	_, pw.err = fmt.Fprintf(pw.w, "%s %s\n", name, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// histograms 写出按 method 和 code 分组的请求计数器和耗时直方图
+func (pw *promWriter) histograms(prefix, help string, buckets []float64, histograms map[metricKey]*histogram) {
+	keys := make([]metricKey, 0, len(histograms))
+	for key := range histograms {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].code < keys[j].code
+	})
+
+	pw.help(prefix+"s_total", "counter", help+"数")
+	for _, key := range keys {
+		pw.sample(prefix+"s_total", labels("method", key.method, "code", key.code), float64(histograms[key].count))
+	}
+
+	name := prefix + "_duration_seconds"
+	pw.help(name, "histogram", help+"耗时（秒）")
+	for _, key := range keys {
+		h := histograms[key]
+		base := labels("method", key.method, "code", key.code)
+
+		var cumulative uint64
+		for i, upper := range buckets {
+			cumulative += h.counts[i]
+			pw.sample(name+"_bucket", base+","+labels("le", strconv.FormatFloat(upper, 'g', -1, 64)), float64(cumulative))
+		}
+		pw.sample(name+"_bucket", base+","+labels("le", "+Inf"), float64(h.count))
+		pw.sample(name+"_sum", base, h.sum)
+		pw.sample(name+"_count", base, float64(h.count))
+	}
+}
+
+// labelEscaper 转义标签值中的反斜杠、双引号和换行符
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels 将键值对格式化为标签
+func labels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// sortedKeys 返回按字典序排序的 map 键
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,250 @@
+package rerpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPrometheusMetrics 测试服务端、客户端和连接池的指标
+func TestPrometheusMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+
+	server := NewServerWithConfig(ServerConfig{Workers: 4, Metrics: metrics})
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	address := listener.Addr().String()
+	client, err := NewClient(ClientConfig{Address: address, Metrics: metrics})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		if err := client.Call(ctx, "TestService.Add", &AddArgs{A: 1, B: 2}, &AddReply{}); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+	}
+	if err := client.Call(ctx, "Missing.Method", &AddArgs{}, &AddReply{}); err == nil {
+		t.Fatal("Expected method not found error")
+	}
+
+	var out strings.Builder
+	if err := metrics.Export(&out); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	text := out.String()
+
+	want := []string{
+		`rerpc_server_requests_total{method="TestService.Add",code="ok"} 2`,
+		`rerpc_server_requests_total{method="unknown",code="-32601"} 1`,
+		`rerpc_server_request_duration_seconds_bucket{method="TestService.Add",code="ok",le="+Inf"} 2`,
+		`rerpc_server_request_duration_seconds_count{method="TestService.Add",code="ok"} 2`,
+		`rerpc_server_requests_in_flight{method="TestService.Add"} 0`,
+		`rerpc_client_requests_total{method="TestService.Add",code="ok"} 2`,
+		`rerpc_client_requests_total{method="Missing.Method",code="-32601"} 1`,
+		`rerpc_server_connections 1`,
+		`rerpc_goroutine_pool_workers{pool="server"} 4`,
+		`rerpc_conn_pool_dials_total{pool="` + address + `"} 1`,
+		`rerpc_conn_pool_reuses_total{pool="` + address + `"} 2`,
+		"# TYPE rerpc_server_request_duration_seconds histogram",
+	}
+	for _, line := range want {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Expected %q in metrics:\n%s", line, text)
+		}
+	}
+	for _, name := range []string{"rerpc_server_received_bytes_total", "rerpc_server_sent_bytes_total"} {
+		if !regexp.MustCompile(`(?m)^` + name + ` [1-9]\d*$`).MatchString(text) {
+			t.Errorf("Expected non-zero %s in metrics:\n%s", name, text)
+		}
+	}
+
+	// 客户端关闭后连接数归零
+	client.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		out.Reset()
+		metrics.Export(&out)
+		if strings.Contains(out.String(), "rerpc_server_connections 0\n") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected connections to drop to 0:\n%s", out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestPrometheusMetrics_Handler 测试指标的 HTTP 输出
+func TestPrometheusMetrics_Handler(t *testing.T) {
+	metrics := NewPrometheusMetrics(0.1, 0.01)
+	metrics.ServerRequestStarted("A.B")
+	metrics.ServerRequestFinished("A.B", nil, 50*time.Millisecond)
+	metrics.ClientCallFinished("Quote\"d", context.DeadlineExceeded, time.Second)
+
+	ts := httptest.NewServer(metrics.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	text := string(body)
+
+	// 分桶按上界排序并累加
+	want := []string{
+		`rerpc_server_request_duration_seconds_bucket{method="A.B",code="ok",le="0.01"} 0`,
+		`rerpc_server_request_duration_seconds_bucket{method="A.B",code="ok",le="0.1"} 1`,
+		`rerpc_server_request_duration_seconds_bucket{method="A.B",code="ok",le="+Inf"} 1`,
+		`rerpc_server_request_duration_seconds_sum{method="A.B",code="ok"} 0.05`,
+		`rerpc_client_requests_total{method="Quote\"d",code="deadline_exceeded"} 1`,
+	}
+	for _, line := range want {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Expected %q in metrics:\n%s", line, text)
+		}
+	}
+}
+
+// stalledWriter 模拟卡住的抓取方：第一次写入时通知 started，然后阻塞直到 release 被关闭
+type stalledWriter struct {
+	started chan struct{}
+	release chan struct{}
+	once    bool
+}
+
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	if !w.once {
+		w.once = true
+		close(w.started)
+	}
+	<-w.release
+	return len(p), nil
+}
+
+// TestPrometheusMetrics_SlowScraper 测试写出指标时不持有锁，慢速的抓取方不会阻塞请求
+func TestPrometheusMetrics_SlowScraper(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	// 足够多的方法，使写出的内容超过缓冲区大小
+	for i := 0; i < 100; i++ {
+		metrics.ServerRequestFinished("Service.Method"+strconv.Itoa(i), nil, time.Millisecond)
+	}
+
+	w := &stalledWriter{started: make(chan struct{}), release: make(chan struct{})}
+	exported := make(chan struct{})
+	go func() {
+		defer close(exported)
+		metrics.Export(w)
+	}()
+	<-w.started
+
+	recorded := make(chan struct{})
+	go func() {
+		defer close(recorded)
+		metrics.ServerRequestStarted("Service.Method0")
+		metrics.ServerRequestFinished("Service.Method0", nil, time.Millisecond)
+	}()
+	select {
+	case <-recorded:
+	case <-time.After(5 * time.Second):
+		t.Error("Recording metrics was blocked by a stalled scraper")
+	}
+
+	close(w.release)
+	<-exported
+}
+
+// TestPrometheusMetrics_SharedConnPool 测试连接同一地址的多个客户端的连接池指标相加，关闭其中一个不影响另一个
+func TestPrometheusMetrics_SharedConnPool(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+
+	server := NewServer(4)
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	address := listener.Addr().String()
+	clients := make([]*Client, 2)
+	for i := range clients {
+		client, err := NewClient(ClientConfig{Address: address, Metrics: metrics})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		defer client.Close()
+		if err := client.Call(ctx, "TestService.Add", &AddArgs{A: 1, B: 2}, &AddReply{}); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		clients[i] = client
+	}
+
+	export := func() string {
+		var out strings.Builder
+		if err := metrics.Export(&out); err != nil {
+			t.Fatalf("Export failed: %v", err)
+		}
+		return out.String()
+	}
+	expect := func(text string, lines ...string) {
+		t.Helper()
+		for _, line := range lines {
+			if !strings.Contains(text, line+"\n") {
+				t.Errorf("Expected %q in metrics:\n%s", line, text)
+			}
+		}
+	}
+
+	pool := `{pool="` + address + `"}`
+	expect(export(),
+		"rerpc_conn_pool_idle_connections"+pool+" 2",
+		"rerpc_conn_pool_dials_total"+pool+" 2",
+	)
+
+	// 关闭的连接池不再计入连接数，计数器保持不变
+	clients[0].Close()
+	expect(export(),
+		"rerpc_conn_pool_idle_connections"+pool+" 1",
+		"rerpc_conn_pool_dials_total"+pool+" 2",
+	)
+	if err := clients[1].Call(ctx, "TestService.Add", &AddArgs{A: 1, B: 2}, &AddReply{}); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	expect(export(),
+		"rerpc_conn_pool_active_connections"+pool+" 1",
+		"rerpc_conn_pool_reuses_total"+pool+" 1",
+	)
+}
@@ -0,0 +1,66 @@
+package rerpc
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Handler 服务端处理一次 RPC 调用（包括通知）
+// method 为完整方法名（如 "Arith.Add"），params 为请求中的原始参数
+// 返回的 reply 会被编码为响应的 result，error 会被转换为 JSON-RPC 错误（*Error 和 CodedError 保留错误码）
+type Handler func(ctx context.Context, method string, params json.RawMessage) (reply interface{}, err error)
+
+// Middleware 服务端拦截器
+// 包装下一个 Handler，可以在调用前后执行逻辑（日志、鉴权、指标、参数校验、链路追踪等），
+// 也可以不调用 next 直接返回错误来拒绝请求
+type Middleware func(next Handler) Handler
+
+// Use 添加服务端拦截器
+// 先添加的拦截器位于外层，最先看到请求、最后看到结果
+// 必须在启动服务之前调用
+func (s *Server) Use(middlewares ...Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.middlewares = append(s.middlewares, middlewares...)
+	s.handler.Store(chainMiddlewares(s.middlewares, s.invoke))
+}
+
+// chainMiddlewares 将拦截器按顺序包装在 handler 外层
+func chainMiddlewares(middlewares []Middleware, handler Handler) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// invoke 拦截器链最内层的 Handler：解析方法名并调用注册的服务方法
+func (s *Server) invoke(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	// 解析服务名和方法名
+	// 格式：ServiceName.MethodName
+	serviceName, methodName, err := parseMethod(method)
+	if err != nil {
+		return nil, NewMethodNotFoundError(method)
+	}
+
+	// 调用服务方法
+	// 性能优化：使用缓存的反射信息，避免运行时反射开销
+	return s.registry.Call(ctx, serviceName, methodName, params)
+}
+
+// Invoker 客户端执行一次 RPC 调用
+// reply 为 nil 时表示通知（Client.Notify），不等待响应
+type Invoker func(ctx context.Context, method string, args, reply interface{}) error
+
+// ClientMiddleware 客户端拦截器
+// 包装下一个 Invoker，拦截 Call、Go、Batch 和 Notify 发起的每一次逻辑调用（重试在拦截器内部进行）
+type ClientMiddleware func(next Invoker) Invoker
+
+// chainClientMiddlewares 将客户端拦截器按顺序包装在 invoker 外层
+// 先配置的拦截器位于外层
+func chainClientMiddlewares(middlewares []ClientMiddleware, invoker Invoker) Invoker {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		invoker = middlewares[i](invoker)
+	}
+	return invoker
+}
@@ -0,0 +1,207 @@
+package rerpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestServer_Middleware 测试服务端拦截器的顺序、观察结果和拒绝请求
+func TestServer_Middleware(t *testing.T) {
+	server := newTestServer(t)
+
+	var (
+		mu    sync.Mutex
+		trace []string
+	)
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+				mu.Lock()
+				trace = append(trace, name+":before:"+method)
+				mu.Unlock()
+
+				reply, err := next(ctx, method, params)
+
+				mu.Lock()
+				if r, ok := reply.(*AddReply); ok {
+					trace = append(trace, name+":after:"+strconv.Itoa(r.Result))
+				} else {
+					trace = append(trace, name+":after:"+err.Error())
+				}
+				mu.Unlock()
+				return reply, err
+			}
+		}
+	}
+
+	// 拒绝 Echo 调用，返回自定义错误码
+	deny := func(next Handler) Handler {
+		return func(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+			if method == "TestService.Echo" {
+				return nil, NewError(403, "forbidden", nil)
+			}
+			return next(ctx, method, params)
+		}
+	}
+
+	server.Use(record("outer"), record("inner"))
+	server.Use(deny)
+
+	out := server.processRequest(context.Background(), []byte(`{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2},"id":1}`))
+	if !strings.Contains(string(out), `"result":{"result":3}`) {
+		t.Errorf("Unexpected response: %s", out)
+	}
+
+	want := []string{
+		"outer:before:TestService.Add",
+		"inner:before:TestService.Add",
+		"inner:after:3",
+		"outer:after:3",
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("Expected trace %v, got %v", want, trace)
+	}
+
+	out = server.processRequest(context.Background(), []byte(`{"jsonrpc":"2.0","method":"TestService.Echo","params":{"message":"hi"},"id":2}`))
+	if !strings.Contains(string(out), `"code":403`) {
+		t.Errorf("Expected forbidden error, got %s", out)
+	}
+
+	// 未注册的方法也经过拦截器
+	trace = nil
+	out = server.processRequest(context.Background(), []byte(`{"jsonrpc":"2.0","method":"Missing","id":3}`))
+	if !strings.Contains(string(out), `"code":-32601`) {
+		t.Errorf("Expected method not found, got %s", out)
+	}
+	if len(trace) != 4 || trace[0] != "outer:before:Missing" {
+		t.Errorf("Unexpected trace %v", trace)
+	}
+}
+
+// TestClient_Middleware 测试客户端拦截器
+func TestClient_Middleware(t *testing.T) {
+	server := newTestServer(t)
+	ts := httptest.NewServer(server.HTTPHandler())
+	defer ts.Close()
+
+	var (
+		mu    sync.Mutex
+		trace []string
+	)
+	record := func(name string) ClientMiddleware {
+		return func(next Invoker) Invoker {
+			return func(ctx context.Context, method string, args, reply interface{}) error {
+				err := next(ctx, method, args, reply)
+
+				mu.Lock()
+				defer mu.Unlock()
+				switch r := reply.(type) {
+				case nil:
+					trace = append(trace, name+":notify:"+method)
+				case *AddReply:
+					trace = append(trace, name+":call:"+method+":"+strconv.Itoa(r.Result))
+				default:
+					trace = append(trace, name+":call:"+method)
+				}
+				return err
+			}
+		}
+	}
+
+	errBlocked := errors.New("blocked")
+	block := func(next Invoker) Invoker {
+		return func(ctx context.Context, method string, args, reply interface{}) error {
+			if method == "TestService.Echo" {
+				return errBlocked
+			}
+			return next(ctx, method, args, reply)
+		}
+	}
+
+	client, err := NewClient(ClientConfig{
+		Address:     ts.URL,
+		Middlewares: []ClientMiddleware{record("outer"), record("inner"), block},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Call(ctx, "TestService.Add", &AddArgs{A: 2, B: 3}, &AddReply{}); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if err := client.Notify(ctx, "TestService.Add", &AddArgs{A: 1, B: 1}); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if err := client.Call(ctx, "TestService.Echo", &EchoArgs{Message: "hi"}, &EchoReply{}); err != errBlocked {
+		t.Errorf("Expected errBlocked, got %v", err)
+	}
+
+	want := []string{
+		"inner:call:TestService.Add:5",
+		"outer:call:TestService.Add:5",
+		"inner:notify:TestService.Add",
+		"outer:notify:TestService.Add",
+		"inner:call:TestService.Echo",
+		"outer:call:TestService.Echo",
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("Expected trace %v, got %v", want, trace)
+	}
+}
+
+// TestServer_MiddlewarePanic 测试拦截器中的 panic 转换为内部错误响应，不会导致进程崩溃
+func TestServer_MiddlewarePanic(t *testing.T) {
+	server := NewServerWithConfig(ServerConfig{Workers: 1})
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	server.Use(func(next Handler) Handler {
+		return func(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+			if method == "TestService.Echo" {
+				panic("middleware panic")
+			}
+			return next(ctx, method, params)
+		}
+	})
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	client, err := NewClient(ClientConfig{Address: listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var echo EchoReply
+	err = client.Call(ctx, "TestService.Echo", &EchoArgs{Message: "hi"}, &echo)
+	if rpcErr, ok := AsError(err); !ok || rpcErr.Code != ErrCodeInternal {
+		t.Fatalf("Expected internal error, got %v", err)
+	}
+
+	// 服务器继续处理其他请求
+	var reply AddReply
+	if err := client.Call(ctx, "TestService.Add", &AddArgs{A: 1, B: 2}, &reply); err != nil || reply.Result != 3 {
+		t.Fatalf("Expected call to succeed, got %v, %v", reply.Result, err)
+	}
+}
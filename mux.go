@@ -0,0 +1,311 @@
+package rerpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errConnLost 表示多路复用连接在等待响应期间断开
+var errConnLost = errors.New("connection lost")
+
+// muxConn 多路复用连接
+// 性能优化：
+// 1. 一个长连接上并发发送多个请求，避免每个调用独占一个 TCP 连接
+// 2. 写入由 messageConn 串行化，每个请求是一条完整的消息，不会交错
+// 3. 单个读协程按响应 ID 查找 Client.pending，将响应投递给对应的 Call
+type muxConn struct {
+	client  *Client
+	conn    messageConn
+	release func() // 释放底层连接（归还连接池计数或关闭 WebSocket）
+
+	closed   int32     // 关闭标志（原子操作）
+	failOnce sync.Once // 确保失败处理只执行一次
+	err      error     // 连接失败原因（closed 置位后只读）
+}
+
+// newMuxConn 包装连接并启动读协程
+func newMuxConn(c *Client, conn messageConn, release func()) *muxConn {
+	mc := &muxConn{
+		client:  c,
+		conn:    conn,
+		release: release,
+	}
+	go mc.readLoop()
+	return mc
+}
+
+// isClosed 检查连接是否已失效
+func (mc *muxConn) isClosed() bool {
+	return atomic.LoadInt32(&mc.closed) == 1
+}
+
+// write 写入一条完整的消息
+// ctx 的截止时间作为本次写入的超时时间
+func (mc *muxConn) write(ctx context.Context, data []byte) error {
+	if mc.isClosed() {
+		return mc.err
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := mc.conn.WriteMessage(data, deadline); err != nil {
+		mc.fail(err)
+		return fmt.Errorf("failed to write request: %w", err)
+	}
+	return nil
+}
+
+// readLoop 读协程，持续读取响应并分发给等待中的调用
+func (mc *muxConn) readLoop() {
+	c := mc.client
+
+	for {
+		data, err := mc.conn.ReadMessage()
+		if err != nil {
+			mc.fail(err)
+			return
+		}
+
+		resp, err := c.codec.DecodeResponse(data)
+		if err != nil {
+			// 不是响应：可能是服务端推送的通知
+			c.handleServerMessage(data)
+			continue
+		}
+
+		// 按 ID 查找对应的调用，找到后立即从 pending 移除
+		var call *Call
+		if id, ok := resp.ID.(float64); ok {
+			seq := uint64(id)
+			c.mu.Lock()
+			call = c.pending[seq]
+			if call != nil && call.mc == mc {
+				delete(c.pending, seq)
+			} else {
+				call = nil
+			}
+			c.mu.Unlock()
+		}
+
+		if call == nil {
+			// 调用已超时或取消，丢弃迟到的响应
+			c.logger.Debug("dropping late response", "id", resp.ID)
+			c.codec.(*JSONCodec).pool.PutResponse(resp)
+			continue
+		}
+
+		// resp channel 有 1 个缓冲，不会阻塞读协程
+		call.resp <- resp
+	}
+}
+
+// fail 将连接标记为失效，关闭底层连接
+// 并通知所有在该连接上等待响应的调用
+func (mc *muxConn) fail(err error) {
+	mc.failOnce.Do(func() {
+		// 客户端关闭时连接断开是预期行为，不记录日志
+		if !mc.client.isClosed() {
+			mc.client.logger.Warn("connection lost", "remote_addr", mc.conn.RemoteAddr().String(), "error", err)
+		}
+		mc.err = fmt.Errorf("%w: %w", errConnLost, err)
+		atomic.StoreInt32(&mc.closed, 1)
+		mc.release()
+
+		c := mc.client
+		c.mu.Lock()
+		for seq, call := range c.pending {
+			if call.mc == mc {
+				delete(c.pending, seq)
+				call.resp <- nil
+			}
+		}
+		c.mu.Unlock()
+	})
+}
+
+// getMuxConn 轮询选取一个多路复用连接
+// 连接不存在或已失效时通过连接池重新建立
+func (c *Client) getMuxConn() (*muxConn, error) {
+	idx := int(atomic.AddUint32(&c.muxNext, 1) % uint32(len(c.muxConns)))
+
+	c.muxMu.Lock()
+	defer c.muxMu.Unlock()
+
+	if c.isClosed() {
+		return nil, ErrClientClosed
+	}
+
+	if mc := c.muxConns[idx]; mc != nil && !mc.isClosed() {
+		return mc, nil
+	}
+
+	conn, release, err := c.dialMux()
+	if err != nil {
+		return nil, err
+	}
+
+	mc := newMuxConn(c, conn, release)
+	c.muxConns[idx] = mc
+	return mc, nil
+}
+
+// dialMux 建立一个多路复用长连接
+// WebSocket 传输直接拨号；TCP 传输从连接池获取连接并长期持有
+func (c *Client) dialMux() (messageConn, func(), error) {
+	if c.wsURL != "" {
+		conn, err := dialWebSocket(c.wsURL, c.dialTimeout, c.tlsConfig, c.connOptions())
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrNoConnection, err)
+		}
+		return conn, func() { conn.Close() }, nil
+	}
+
+	conn, err := c.connPool.Get()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrNoConnection, err)
+	}
+	return newLineConn(conn, c.connOptions()), func() { c.connPool.Discard(conn) }, nil
+}
+
+// handleServerMessage 处理服务端主动推送的消息
+// 只处理通知，交给 OnNotification 回调；未设置回调时直接丢弃
+func (c *Client) handleServerMessage(data []byte) {
+	if c.onNotification == nil {
+		return
+	}
+
+	req, err := c.codec.DecodeRequest(data)
+	if err != nil {
+		c.logger.Debug("dropping invalid server message", "error", err)
+		return
+	}
+	defer c.codec.(*JSONCodec).pool.PutRequest(req)
+
+	if req.IsNotification() {
+		c.onNotification(req.Method, req.Params)
+	}
+}
+
+// closeMuxConns 关闭所有多路复用连接
+func (c *Client) closeMuxConns() {
+	c.muxMu.Lock()
+	defer c.muxMu.Unlock()
+
+	for i, mc := range c.muxConns {
+		if mc != nil {
+			mc.fail(ErrClientClosed)
+			c.muxConns[i] = nil
+		}
+	}
+}
+
+// doMuxCall 在多路复用连接上执行单次 RPC 调用
+// 请求写出后在 call.resp 上等待读协程投递的响应
+func (c *Client) doMuxCall(ctx context.Context, call *Call) error {
+	mc, err := c.getMuxConn()
+	if err != nil {
+		return err
+	}
+
+	// 编码请求
+	seq := c.nextSeq()
+	reqData, err := c.encodeCall(ctx, seq, call)
+	if err != nil {
+		return err
+	}
+
+	// 注册待处理的调用
+	// 必须在写出请求之前注册，否则响应可能先于注册到达
+	call.seq = seq
+	call.mc = mc
+	call.resp = make(chan *Response, 1)
+
+	c.mu.Lock()
+	if mc.isClosed() {
+		// 连接在选取之后失效，fail 已经遍历过 pending
+		c.mu.Unlock()
+		return mc.err
+	}
+	c.pending[seq] = call
+	c.mu.Unlock()
+
+	if err := mc.write(ctx, reqData); err != nil {
+		c.removePending(seq)
+		return err
+	}
+
+	// 等待响应或超时
+	select {
+	case resp := <-call.resp:
+		if resp == nil {
+			return mc.err
+		}
+		defer c.codec.(*JSONCodec).pool.PutResponse(resp)
+		return c.handleResponse(resp, call)
+	case <-ctx.Done():
+		c.removePending(seq)
+		// 通知服务端取消请求，迟到的响应会被读协程丢弃
+		if data, err := c.encodeCancel(seq); err == nil {
+			if err := mc.conn.WriteMessage(data, time.Now().Add(cancelWriteTimeout)); err == nil {
+				atomic.AddUint64(&c.cancelled, 1)
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// removePending 从 pending 中移除调用
+func (c *Client) removePending(seq uint64) {
+	c.mu.Lock()
+	delete(c.pending, seq)
+	c.mu.Unlock()
+}
+
+// encodeCall 将调用编码为请求消息
+// ctx 的剩余超时时间随请求发送给服务端
+func (c *Client) encodeCall(ctx context.Context, seq uint64, call *Call) ([]byte, error) {
+	req := c.codec.(*JSONCodec).pool.GetRequest()
+	defer c.codec.(*JSONCodec).pool.PutRequest(req)
+
+	req.Jsonrpc = JSONRPCVersion
+	req.Method = call.ServiceMethod
+	req.ID = seq
+	req.Timeout = requestTimeout(ctx)
+	injectTraceContext(ctx, req)
+
+	// 序列化参数
+	if call.Args != nil {
+		argsData, err := json.Marshal(call.Args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal args: %w", err)
+		}
+		req.Params = argsData
+	}
+	if err := c.setRequestMetadata(ctx, req); err != nil {
+		return nil, err
+	}
+
+	reqData, err := c.codec.EncodeRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	return reqData, nil
+}
+
+// requestTimeout 计算 ctx 的剩余超时时间（毫秒），没有截止时间时返回 0
+// 不足 1 毫秒的部分向上取整，避免把即将到期的请求当作不限时
+func requestTimeout(ctx context.Context) int64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return 1
+	}
+	return int64((remaining + time.Millisecond - 1) / time.Millisecond)
+}
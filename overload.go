@@ -0,0 +1,109 @@
+package rerpc
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// overloadWriteTimeout 向被拒绝的连接写出过载错误的超时时间
+const overloadWriteTimeout = time.Second
+
+// OverloadPolicy 协程池队列已满时服务器对新连接的处理策略
+type OverloadPolicy int
+
+const (
+	// OverloadBlock 等待队列出现空闲位置（默认）
+	// 等待期间不再接受新连接，新连接积压在内核的 backlog 中
+	OverloadBlock OverloadPolicy = iota
+
+	// OverloadReject 立即拒绝新连接：写出过载错误后关闭
+	OverloadReject
+
+	// OverloadShedOldest 丢弃队列中等待最久的连接（写出过载错误后关闭），接受新连接
+	// 适合等待过久的客户端大多已经超时放弃的场景
+	OverloadShedOldest
+)
+
+// String 返回策略名称
+func (p OverloadPolicy) String() string {
+	switch p {
+	case OverloadBlock:
+		return "block"
+	case OverloadReject:
+		return "reject"
+	case OverloadShedOldest:
+		return "shed-oldest"
+	default:
+		return "unknown"
+	}
+}
+
+// acquireConn 占用一个连接名额，超过最大连接数时返回 false
+// 高优先级连接总是被接受（仍然计入连接数）
+// 占用成功的连接在 serveConn 返回时释放名额
+func (s *Server) acquireConn(priority Priority) bool {
+	active := atomic.AddInt64(&s.activeConns, 1)
+	if s.maxConns > 0 && active > int64(s.maxConns) && priority != PriorityHigh {
+		atomic.AddInt64(&s.activeConns, -1)
+		return false
+	}
+	return true
+}
+
+// releaseConn 释放连接名额
+func (s *Server) releaseConn() {
+	atomic.AddInt64(&s.activeConns, -1)
+}
+
+// submitConn 按过载策略把连接交给协程池
+// 被丢弃的连接由 onShed 负责清理
+// 高优先级连接进入协程池的高优先级队列，排在等待中的普通连接之前；
+// 高优先级队列已满时按策略阻塞或拒绝，不会挤掉其他连接
+func (s *Server) submitConn(priority Priority, task, onShed func()) error {
+	if priority == PriorityHigh {
+		if s.overloadPolicy == OverloadBlock {
+			return s.pool.SubmitPriority(PriorityHigh, task)
+		}
+		return s.pool.TrySubmitPriority(PriorityHigh, task)
+	}
+
+	switch s.overloadPolicy {
+	case OverloadReject:
+		return s.pool.TrySubmit(task)
+	case OverloadShedOldest:
+		return s.pool.SubmitShedOldest(task, onShed)
+	default:
+		return s.pool.Submit(task)
+	}
+}
+
+// rejectConn 拒绝连接：写出过载错误后关闭
+// 在单独的协程中写出，TLS 握手和慢速客户端不会阻塞接受连接的循环
+func (s *Server) rejectConn(conn net.Conn) {
+	atomic.AddUint64(&s.rejectedConns, 1)
+
+	data := s.encodeErrorResponse(nil, NewServerOverloadedError())
+	go func() {
+		defer conn.Close()
+		conn.SetWriteDeadline(time.Now().Add(overloadWriteTimeout))
+		conn.Write(data)
+	}()
+}
+
+// admitRequest 占用一个请求名额，超过最大并发请求数时返回 false
+// 高优先级请求总是被接受（仍然计入并发请求数）
+// 无论是否成功，调用者都必须在请求结束时调用 releaseRequest
+func (s *Server) admitRequest(priority Priority) bool {
+	active := atomic.AddInt64(&s.activeRequests, 1)
+	if s.maxRequests > 0 && active > int64(s.maxRequests) && priority != PriorityHigh {
+		atomic.AddUint64(&s.rejectedRequests, 1)
+		return false
+	}
+	return true
+}
+
+// releaseRequest 释放请求名额
+func (s *Server) releaseRequest() {
+	atomic.AddInt64(&s.activeRequests, -1)
+}
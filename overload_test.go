@@ -0,0 +1,404 @@
+package rerpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockPool 占满协程池：worker 执行一个阻塞任务，队列中放满任务
+// 返回解除阻塞的函数
+func blockPool(t *testing.T, pool *GoroutinePool, queued int) func() {
+	t.Helper()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	if err := pool.Submit(func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	<-started
+	for i := 0; i < queued; i++ {
+		if err := pool.TrySubmit(func() {}); err != nil {
+			t.Fatalf("TrySubmit failed: %v", err)
+		}
+	}
+	return func() { close(release) }
+}
+
+// TestGoroutinePool_TrySubmit 测试队列已满时立即返回
+func TestGoroutinePool_TrySubmit(t *testing.T) {
+	pool := NewGoroutinePool(1, 1)
+	release := blockPool(t, pool, 1)
+
+	if err := pool.TrySubmit(func() {}); !errors.Is(err, ErrPoolFull) {
+		t.Errorf("Expected ErrPoolFull, got %v", err)
+	}
+
+	release()
+	pool.Close()
+	if err := pool.TrySubmit(func() {}); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Expected ErrPoolClosed, got %v", err)
+	}
+}
+
+// TestGoroutinePool_SubmitContext 测试等待队列空闲位置时的超时
+func TestGoroutinePool_SubmitContext(t *testing.T) {
+	pool := NewGoroutinePool(1, 1)
+	defer pool.Close()
+	release := blockPool(t, pool, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.SubmitContext(ctx, func() {}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+
+	// 队列出现空闲位置后提交成功
+	done := make(chan struct{})
+	go release()
+	if err := pool.SubmitContext(context.Background(), func() { close(done) }); err != nil {
+		t.Fatalf("SubmitContext failed: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Task was not executed")
+	}
+}
+
+// TestGoroutinePool_SubmitShedOldest 测试丢弃队列中最旧的任务
+func TestGoroutinePool_SubmitShedOldest(t *testing.T) {
+	pool := NewGoroutinePool(1, 2)
+	defer pool.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	ran := make(chan string, 3)
+	shed := make(chan string, 3)
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		if err := pool.SubmitShedOldest(func() { ran <- name }, func() { shed <- name }); err != nil {
+			t.Fatalf("SubmitShedOldest failed: %v", err)
+		}
+	}
+
+	if got := <-shed; got != "a" {
+		t.Errorf("Expected oldest task a to be shed, got %s", got)
+	}
+	if stats := pool.Stats(); stats.Shed != 1 {
+		t.Errorf("Expected 1 shed task, got %d", stats.Shed)
+	}
+
+	close(release)
+	for _, want := range []string{"b", "c"} {
+		select {
+		case got := <-ran:
+			if got != want {
+				t.Errorf("Expected task %s, got %s", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Task was not executed")
+		}
+	}
+}
+
+// TestGoroutinePool_ParallelNoQueue 测试 worker 全部忙碌时子任务由调用者完成，帮手不占用任务队列
+func TestGoroutinePool_ParallelNoQueue(t *testing.T) {
+	pool := NewGoroutinePool(1, 2)
+	defer pool.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	pool.Submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	var count int32
+	pool.parallel(4, func(int) { atomic.AddInt32(&count, 1) })
+	if count != 4 {
+		t.Fatalf("Expected 4 subtasks to run, got %d", count)
+	}
+	if stats := pool.Stats(); stats.Queued != 0 {
+		t.Fatalf("Expected no queued helpers, got %+v", stats)
+	}
+
+	// 队列位置仍然可以用于新任务
+	for i := 0; i < 2; i++ {
+		if err := pool.TrySubmit(func() {}); err != nil {
+			t.Fatalf("TrySubmit %d failed: %v", i, err)
+		}
+	}
+}
+
+// TestGoroutinePool_SubmitDuringClose 测试关闭时并发提交的任务不会向已关闭的队列发送
+func TestGoroutinePool_SubmitDuringClose(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		pool := NewGoroutinePool(2, 1)
+		var wg sync.WaitGroup
+		submitters := []func(task func()) error{
+			pool.Submit,
+			pool.TrySubmit,
+			func(task func()) error { return pool.SubmitContext(context.Background(), task) },
+			func(task func()) error { return pool.SubmitShedOldest(task, nil) },
+			func(task func()) error { pool.parallel(4, func(int) {}); return nil },
+		}
+		for _, submit := range submitters {
+			wg.Add(1)
+			go func(submit func(task func()) error) {
+				defer wg.Done()
+				for {
+					err := submit(func() { time.Sleep(time.Microsecond) })
+					if errors.Is(err, ErrPoolClosed) || pool.IsClosed() {
+						return
+					}
+				}
+			}(submit)
+		}
+		time.Sleep(time.Millisecond)
+		pool.Close()
+		wg.Wait()
+	}
+}
+
+// TestGoroutinePool_Elastic 测试弹性协程池扩容到最大数量并回收空闲的工作协程
+func TestGoroutinePool_Elastic(t *testing.T) {
+	pool := NewGoroutinePoolWithConfig(GoroutinePoolConfig{
+		MinWorkers:  1,
+		MaxWorkers:  3,
+		IdleTimeout: 20 * time.Millisecond,
+	})
+	defer pool.Close()
+
+	// 没有空闲 worker 时扩容，达到最大数量后任务进入队列（队列为 0 时阻塞）
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+	for i := 0; i < 3; i++ {
+		if err := pool.Submit(func() {
+			started <- struct{}{}
+			<-release
+		}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+	if stats := pool.Stats(); stats.Workers != 3 || stats.Busy != 3 {
+		t.Errorf("Expected 3 busy workers, got %+v", stats)
+	}
+	if err := pool.TrySubmit(func() {}); !errors.Is(err, ErrPoolFull) {
+		t.Errorf("Expected ErrPoolFull at max workers, got %v", err)
+	}
+
+	// 空闲超时后回收到最小数量
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.Stats().Workers != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	stats := pool.Stats()
+	if stats.Workers != 1 || stats.MinWorkers != 1 || stats.MaxWorkers != 3 {
+		t.Errorf("Expected pool to shrink to 1 worker, got %+v", stats)
+	}
+	if stats.Completed != 3 {
+		t.Errorf("Expected 3 completed tasks, got %d", stats.Completed)
+	}
+
+	// 回收后仍然可以再次扩容
+	done := make(chan struct{})
+	if err := pool.Submit(func() { close(done) }); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Task was not executed")
+	}
+}
+
+// TestGoroutinePool_RetireRace 测试 worker 空闲超时退出与任务入队同时发生时任务不会一直等待
+func TestGoroutinePool_RetireRace(t *testing.T) {
+	pool := NewGoroutinePoolWithConfig(GoroutinePoolConfig{
+		MinWorkers:  1,
+		MaxWorkers:  2,
+		QueueSize:   1,
+		IdleTimeout: time.Millisecond,
+	})
+	defer pool.Close()
+
+	// 最小数量的 worker 一直忙碌，任务只能由弹性扩容的 worker 执行
+	release := blockPool(t, pool, 0)
+	defer release()
+
+	for i := 0; i < 300; i++ {
+		done := make(chan struct{})
+		if err := pool.Submit(func() { close(done) }); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Task %d was stranded in the queue", i)
+		}
+		// 在空闲超时附近提交下一个任务
+		time.Sleep(time.Duration(i%3) * 500 * time.Microsecond)
+	}
+}
+
+// TestGoroutinePool_PanicStats 测试任务 panic 被计数且不影响 worker
+func TestGoroutinePool_PanicStats(t *testing.T) {
+	pool := NewGoroutinePool(1, 1)
+	pool.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	pool.Submit(func() { panic("boom") })
+	done := make(chan struct{})
+	pool.Submit(func() { close(done) })
+	<-done
+	pool.Close()
+
+	stats := pool.Stats()
+	if stats.Panicked != 1 || stats.Completed != 1 {
+		t.Errorf("Expected 1 panicked and 1 completed task, got %+v", stats)
+	}
+	if stats.Workers != 0 {
+		t.Errorf("Expected no workers after close, got %d", stats.Workers)
+	}
+}
+
+// readOverloaded 读取服务端拒绝连接时写出的过载错误
+func readOverloaded(t *testing.T, conn net.Conn) {
+	t.Helper()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.Contains(line, `"code":-32003`) {
+		t.Fatalf("Expected overloaded error, got %q, %v", line, err)
+	}
+	if _, err := reader.ReadByte(); err == nil {
+		t.Error("Expected rejected connection to be closed")
+	}
+}
+
+// TestServer_OverloadPolicy 测试协程池已满时拒绝新连接或丢弃最旧的连接
+func TestServer_OverloadPolicy(t *testing.T) {
+	for _, policy := range []OverloadPolicy{OverloadReject, OverloadShedOldest} {
+		t.Run(policy.String(), func(t *testing.T) {
+			server := NewServerWithConfig(ServerConfig{
+				Workers:        1,
+				QueueSize:      1,
+				OverloadPolicy: policy,
+				Logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+			})
+			if err := server.Register(&TestService{}); err != nil {
+				t.Fatalf("Failed to register service: %v", err)
+			}
+			defer server.Close()
+
+			listener := newPipeListener()
+			go server.ServeListener(listener)
+
+			// 第一个连接占用唯一的 worker，第二个连接在队列中等待
+			request := `{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2},"id":1}`
+			first, _ := listener.Dial()
+			defer first.Close()
+			if resp := roundTrip(t, first, request); !strings.Contains(resp, `"result":3`) {
+				t.Fatalf("Unexpected response: %s", resp)
+			}
+			second, _ := listener.Dial()
+			defer second.Close()
+
+			// 第三个连接：reject 时被拒绝；shed-oldest 时挤掉队列中的第二个连接
+			third, _ := listener.Dial()
+			defer third.Close()
+			if policy == OverloadReject {
+				readOverloaded(t, third)
+			} else {
+				readOverloaded(t, second)
+			}
+			if stats := server.Stats(); stats.RejectedConns != 1 {
+				t.Errorf("Expected 1 rejected connection, got %d", stats.RejectedConns)
+			}
+
+			// 第一个连接关闭后，队列中的连接得到处理
+			first.Close()
+			waiting := second
+			if policy == OverloadShedOldest {
+				waiting = third
+			}
+			if resp := roundTrip(t, waiting, request); !strings.Contains(resp, `"result":3`) {
+				t.Fatalf("Unexpected response: %s", resp)
+			}
+		})
+	}
+}
+
+// TestServer_MaxRequests 测试超过最大并发请求数的请求被快速拒绝
+func TestServer_MaxRequests(t *testing.T) {
+	server := NewServerWithConfig(ServerConfig{Workers: 4, MaxRequests: 1})
+	service := newBlockingService()
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	client, err := NewClient(ClientConfig{Address: listener.Addr().String(), Multiplex: true})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Call(ctx, "BlockingService.Wait", &EchoArgs{}, &EchoReply{})
+	<-service.started
+
+	var reply AddReply
+	err = client.Call(context.Background(), "TestService.Add", &AddArgs{A: 1, B: 2}, &reply)
+	if rpcErr, ok := AsError(err); !ok || rpcErr.Code != ErrCodeServerOverloaded {
+		t.Fatalf("Expected overloaded error, got %v", err)
+	}
+	if stats := server.Stats(); stats.RejectedRequests != 1 {
+		t.Errorf("Expected 1 rejected request, got %d", stats.RejectedRequests)
+	}
+
+	// 阻塞的请求结束后恢复
+	cancel()
+	service.waitDone(t)
+	deadline := time.Now().Add(5 * time.Second)
+	for server.Stats().ActiveRequests != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := client.Call(context.Background(), "TestService.Add", &AddArgs{A: 1, B: 2}, &reply); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+}
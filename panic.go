@@ -0,0 +1,87 @@
+package rerpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicInfo 捕获到的 panic
+type PanicInfo struct {
+	Value     interface{} // recover() 返回的值
+	Stack     []byte      // 发生 panic 的协程的调用栈
+	Method    string      // 发生 panic 的 RPC 方法（"Service.Method"），协程池任务为空
+	RequestID interface{} // 请求 ID，通知和协程池任务为 nil
+
+	// IncidentID 本次 panic 的事件 ID，与隐藏 panic 详情时返回给客户端的错误中的 incident_id 一致
+	IncidentID string
+}
+
+// PanicHandler 处理服务方法或协程池任务中的 panic，用于上报到日志、告警或错误追踪系统
+// 在发生 panic 的协程中同步调用，不应阻塞
+type PanicHandler func(info PanicInfo)
+
+// PanicData 隐藏 panic 详情时内部错误的 data
+// 客户端只能看到通用的错误消息和事件 ID，服务端通过事件 ID 找到对应的 PanicInfo
+type PanicData struct {
+	IncidentID string `json:"incident_id"`
+}
+
+// requestIDKey 请求 ID 的 context 键
+type requestIDKey struct{}
+
+// contextWithRequestID 将请求 ID 存入 ctx，用于 panic 上报
+func contextWithRequestID(ctx context.Context, id interface{}) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestIDFromContext 获取请求 ID，通知或不在请求中时返回 nil
+func requestIDFromContext(ctx context.Context) interface{} {
+	return ctx.Value(requestIDKey{})
+}
+
+// newPanicInfo 在 recover 之后调用，记录 panic 的值和调用栈并生成事件 ID
+func newPanicInfo(value interface{}) PanicInfo {
+	return PanicInfo{
+		Value:      value,
+		Stack:      debug.Stack(),
+		IncidentID: newIncidentID(),
+	}
+}
+
+// newIncidentID 生成随机的事件 ID（16 位十六进制）
+func newIncidentID() string {
+	var buf [8]byte
+	rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
+// SetPanicHandler 设置服务方法 panic 时的回调
+// 必须在开始处理请求之前调用
+func (r *ServiceRegistry) SetPanicHandler(handler PanicHandler) {
+	r.panicHandler = handler
+}
+
+// SetRedactPanics 设置是否隐藏返回给客户端的 panic 详情
+// 默认错误的 data 包含 panic 的值和完整调用栈；开启后 data 只包含事件 ID（PanicData）
+// 必须在开始处理请求之前调用
+func (r *ServiceRegistry) SetRedactPanics(redact bool) {
+	r.redactPanics = redact
+}
+
+// panicError 上报服务方法中的 panic 并转换为返回给客户端的内部错误
+func (r *ServiceRegistry) panicError(ctx context.Context, method string, value interface{}) *Error {
+	info := newPanicInfo(value)
+	info.Method = method
+	info.RequestID = requestIDFromContext(ctx)
+
+	if r.panicHandler != nil {
+		r.panicHandler(info)
+	}
+	if r.redactPanics {
+		return NewInternalError(PanicData{IncidentID: info.IncidentID})
+	}
+	return NewInternalError(fmt.Sprintf("panic: %v\nstack: %s", value, info.Stack))
+}
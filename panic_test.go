@@ -0,0 +1,184 @@
+package rerpc
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGoroutinePool_PanicHandler 测试任务 panic 交给回调处理而不是记录日志
+func TestGoroutinePool_PanicHandler(t *testing.T) {
+	logs := &syncBuffer{}
+	pool := NewGoroutinePool(1, 1)
+	pool.SetLogger(slog.New(slog.NewJSONHandler(logs, nil)))
+	panics := make(chan PanicInfo, 1)
+	pool.SetPanicHandler(func(info PanicInfo) { panics <- info })
+	defer pool.Close()
+
+	pool.Submit(func() { panic("boom") })
+
+	select {
+	case info := <-panics:
+		if info.Value != "boom" || info.Method != "" || info.RequestID != nil {
+			t.Errorf("Unexpected panic info: %+v", info)
+		}
+		if !strings.Contains(string(info.Stack), "goroutine") || len(info.IncidentID) != 16 {
+			t.Errorf("Expected stack and incident ID, got %q, %q", info.Stack, info.IncidentID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Panic handler was not called")
+	}
+
+	if records := logs.records(t); len(records) != 0 {
+		t.Errorf("Expected no log records, got %v", records)
+	}
+}
+
+// panicResponse 调用 PanicService.PanicMethod 并返回错误响应
+func panicResponse(t *testing.T, server *Server) *Error {
+	t.Helper()
+
+	if err := server.Register(new(PanicService)); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	out := server.processRequest(context.Background(), []byte(`{"jsonrpc":"2.0","method":"PanicService.PanicMethod","params":{},"id":"abc"}`))
+
+	var resp struct {
+		Error *Error `json:"error"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil || resp.Error == nil {
+		t.Fatalf("Expected error response, got %s", out)
+	}
+	if resp.Error.Code != ErrCodeInternal || resp.Error.Message != ErrMsgInternal {
+		t.Errorf("Unexpected error: %+v", resp.Error)
+	}
+	return resp.Error
+}
+
+// incidentID 从隐藏详情的错误中取出事件 ID
+func incidentID(t *testing.T, rpcErr *Error) string {
+	t.Helper()
+
+	data, ok := rpcErr.Data.(map[string]interface{})
+	if !ok || len(data) != 1 {
+		t.Fatalf("Expected only incident ID in error data, got %v", rpcErr.Data)
+	}
+	id, _ := data["incident_id"].(string)
+	return id
+}
+
+// TestServer_PanicHandler 测试服务方法 panic 时回调收到方法名和请求 ID，客户端只收到事件 ID
+func TestServer_PanicHandler(t *testing.T) {
+	panics := make(chan PanicInfo, 1)
+	server := NewServerWithConfig(ServerConfig{
+		PanicHandler: func(info PanicInfo) { panics <- info },
+		RedactPanics: true,
+	})
+	defer server.Close()
+
+	id := incidentID(t, panicResponse(t, server))
+
+	info := <-panics
+	if info.Method != "PanicService.PanicMethod" || info.RequestID != "abc" || info.Value != "intentional panic" {
+		t.Errorf("Unexpected panic info: %+v", info)
+	}
+	if info.IncidentID != id {
+		t.Errorf("Expected incident ID %q, got %q", info.IncidentID, id)
+	}
+}
+
+// TestServer_RedactPanicsLogged 测试未设置回调时隐藏的 panic 详情记录到日志
+func TestServer_RedactPanicsLogged(t *testing.T) {
+	logs := &syncBuffer{}
+	server := NewServerWithConfig(ServerConfig{
+		Logger:       slog.New(slog.NewJSONHandler(logs, nil)),
+		RedactPanics: true,
+	})
+	defer server.Close()
+
+	id := incidentID(t, panicResponse(t, server))
+
+	records := logs.records(t)
+	if len(records) != 1 || records[0]["msg"] != "handler panic" || records[0]["incident_id"] != id {
+		t.Fatalf("Unexpected records: %v", records)
+	}
+	if records[0]["method"] != "PanicService.PanicMethod" || records[0]["id"] != "abc" {
+		t.Errorf("Unexpected record: %v", records[0])
+	}
+}
+
+// TestServer_PanicDetails 测试默认情况下错误包含 panic 详情
+func TestServer_PanicDetails(t *testing.T) {
+	server := NewServerWithConfig(ServerConfig{})
+	defer server.Close()
+
+	rpcErr := panicResponse(t, server)
+	if data, _ := rpcErr.Data.(string); !strings.Contains(data, "intentional panic") {
+		t.Errorf("Expected panic details in error data, got %v", rpcErr.Data)
+	}
+}
+
+// panicTracer Start 时 panic 的 Tracer
+type panicTracer struct{}
+
+func (panicTracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span) {
+	panic("tracer panic")
+}
+
+// TestServer_PipelinePanic 测试服务方法之外的组件 panic 时同样经过 PanicHandler 上报并隐藏详情
+func TestServer_PipelinePanic(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ServerConfig
+		value  string
+	}{
+		{
+			name: "authenticator",
+			config: ServerConfig{
+				Authenticator: AuthenticatorFunc(func(ctx context.Context, info *AuthInfo) (*Principal, error) {
+					panic("auth panic")
+				}),
+			},
+			value: "auth panic",
+		},
+		{
+			name: "rate limiter client key",
+			config: ServerConfig{
+				RateLimiter: NewRateLimiter(RateLimiterConfig{
+					PerClient: RateLimit{Rate: 100},
+					ClientKey: func(ctx context.Context, method string) string { panic("client key panic") },
+				}),
+			},
+			value: "client key panic",
+		},
+		{
+			name:   "tracer",
+			config: ServerConfig{Tracer: panicTracer{}},
+			value:  "tracer panic",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			panics := make(chan PanicInfo, 1)
+			config := tt.config
+			config.PanicHandler = func(info PanicInfo) { panics <- info }
+			config.RedactPanics = true
+			server := NewServerWithConfig(config)
+			defer server.Close()
+
+			id := incidentID(t, panicResponse(t, server))
+
+			info := <-panics
+			if info.Method != "PanicService.PanicMethod" || info.RequestID != "abc" || info.Value != tt.value {
+				t.Errorf("Unexpected panic info: %+v", info)
+			}
+			if info.IncidentID != id {
+				t.Errorf("Expected incident ID %q, got %q", info.IncidentID, id)
+			}
+		})
+	}
+}
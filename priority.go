@@ -0,0 +1,79 @@
+package rerpc
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// MetadataPriority 请求元数据中的优先级键，值为 "high" 或 "normal"
+// 只有服务端开启 ServerConfig.PriorityMetadata 时才会使用
+const MetadataPriority = "priority"
+
+// cancelMethodBytes 用于快速识别取消通知
+var cancelMethodBytes = []byte(`"` + CancelRequestMethod + `"`)
+
+// SetMethodPriority 设置方法的优先级，method 为 "Service.Method"
+// 高优先级请求不占用连接的并发名额，不受 MaxRequests 限制
+// 可以在服务运行期间调用
+func (s *Server) SetMethodPriority(method string, priority Priority) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 写时复制，读取时无需加锁
+	old := s.methodPriorities()
+	priorities := make(map[string]Priority, len(old)+1)
+	for m, p := range old {
+		priorities[m] = p
+	}
+	if priority == PriorityNormal {
+		delete(priorities, method)
+	} else {
+		priorities[method] = priority
+	}
+	s.priorities.Store(priorities)
+}
+
+// methodPriorities 返回方法优先级表（只读）
+func (s *Server) methodPriorities() map[string]Priority {
+	priorities, _ := s.priorities.Load().(map[string]Priority)
+	return priorities
+}
+
+// priorityOf 计算请求的优先级
+// 取消通知总是高优先级；开启 PriorityMetadata 时客户端声明的优先级覆盖方法的优先级
+func (s *Server) priorityOf(method string, md Metadata) Priority {
+	if method == CancelRequestMethod {
+		return PriorityHigh
+	}
+	if s.priorityMetadata {
+		if priority, ok := ParsePriority(md[MetadataPriority]); ok {
+			return priority
+		}
+	}
+	return s.methodPriorities()[method]
+}
+
+// requestPriority 返回已解码请求的优先级
+func (s *Server) requestPriority(req *Request) Priority {
+	return s.priorityOf(req.Method, req.Metadata)
+}
+
+// messagePriority 在完整解码之前预读单个请求消息的优先级，批量请求和无法解析的消息为普通优先级
+// 没有配置任何优先级时只识别取消通知，不为每条消息额外解码
+func (s *Server) messagePriority(data []byte) Priority {
+	if isBatch(data) {
+		return PriorityNormal
+	}
+	if len(s.methodPriorities()) == 0 && !s.priorityMetadata && !bytes.Contains(data, cancelMethodBytes) {
+		return PriorityNormal
+	}
+
+	var peek struct {
+		Method   string   `json:"method"`
+		Metadata Metadata `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &peek); err != nil {
+		return PriorityNormal
+	}
+	return s.priorityOf(peek.Method, peek.Metadata)
+}
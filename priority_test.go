@@ -0,0 +1,235 @@
+package rerpc
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runOrder 占满单个 worker 后按顺序提交任务，返回任务的执行顺序
+func runOrder(t *testing.T, pool *GoroutinePool, tasks []struct {
+	name     string
+	priority Priority
+}) []string {
+	t.Helper()
+
+	release := blockPool(t, pool, 0)
+	ran := make(chan string, len(tasks))
+	for _, task := range tasks {
+		name := task.name
+		if err := pool.TrySubmitPriority(task.priority, func() { ran <- name }); err != nil {
+			t.Fatalf("TrySubmitPriority failed: %v", err)
+		}
+	}
+	release()
+
+	order := make([]string, 0, len(tasks))
+	for range tasks {
+		select {
+		case name := <-ran:
+			order = append(order, name)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Task was not executed")
+		}
+	}
+	return order
+}
+
+// TestGoroutinePool_Priority 测试严格优先和加权的出队顺序
+func TestGoroutinePool_Priority(t *testing.T) {
+	tasks := []struct {
+		name     string
+		priority Priority
+	}{
+		{"n1", PriorityNormal},
+		{"n2", PriorityNormal},
+		{"h1", PriorityHigh},
+		{"h2", PriorityHigh},
+	}
+
+	tests := []struct {
+		weight int
+		want   []string
+	}{
+		{0, []string{"h1", "h2", "n1", "n2"}},
+		{1, []string{"h1", "n1", "h2", "n2"}},
+	}
+	for _, tt := range tests {
+		pool := NewGoroutinePoolWithConfig(GoroutinePoolConfig{MinWorkers: 1, QueueSize: 2, PriorityWeight: tt.weight})
+		order := runOrder(t, pool, tasks)
+		pool.Close()
+
+		for i := range tt.want {
+			if order[i] != tt.want[i] {
+				t.Errorf("weight %d: expected order %v, got %v", tt.weight, tt.want, order)
+				break
+			}
+		}
+	}
+}
+
+// TestParsePriority 测试优先级名称的解析
+func TestParsePriority(t *testing.T) {
+	for _, p := range []Priority{PriorityNormal, PriorityHigh} {
+		if got, ok := ParsePriority(p.String()); !ok || got != p {
+			t.Errorf("ParsePriority(%q) = %v, %v", p.String(), got, ok)
+		}
+	}
+	if _, ok := ParsePriority("urgent"); ok {
+		t.Error("Expected unknown priority to fail")
+	}
+}
+
+// TestServer_Priority 测试高优先级请求不受连接并发上限和最大并发请求数限制
+func TestServer_Priority(t *testing.T) {
+	server := NewServerWithConfig(ServerConfig{
+		Workers:          4,
+		ConnConcurrency:  1,
+		MaxRequests:      1,
+		MethodPriorities: map[string]Priority{"TestService.Add": PriorityHigh},
+		PriorityMetadata: true,
+	})
+	service := newBlockingService()
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	// 所有请求共用一个连接，阻塞的请求占满连接的并发名额和服务器的请求名额
+	client, err := NewClient(ClientConfig{Address: listener.Addr().String(), Multiplex: true})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Call(ctx, "BlockingService.Wait", &EchoArgs{}, &EchoReply{})
+	<-service.started
+
+	// 注册时声明的高优先级方法
+	call, cancelCall := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelCall()
+	var reply AddReply
+	if err := client.Call(call, "TestService.Add", &AddArgs{A: 1, B: 2}, &reply); err != nil || reply.Result != 3 {
+		t.Fatalf("Expected high priority call to succeed, got %v, %v", reply.Result, err)
+	}
+
+	// 请求元数据声明的高优先级
+	var echo EchoReply
+	md := WithMetadata(call, Metadata{MetadataPriority: "high"})
+	if err := client.Call(md, "TestService.Echo", &EchoArgs{Message: "hi"}, &echo); err != nil || echo.Message != "hi" {
+		t.Fatalf("Expected metadata priority call to succeed, got %q, %v", echo.Message, err)
+	}
+
+	// 运行期间设置的方法优先级
+	server.SetMethodPriority("TestService.Echo", PriorityHigh)
+	if err := client.Call(call, "TestService.Echo", &EchoArgs{Message: "again"}, &echo); err != nil {
+		t.Fatalf("Expected high priority call to succeed, got %v", err)
+	}
+
+	// 取消通知不会被阻塞在连接的并发名额上
+	cancel()
+	if err := service.waitDone(t); err != context.Canceled {
+		t.Errorf("Expected Canceled, got %v", err)
+	}
+}
+
+// waitPool 等待协程池的统计信息满足条件
+func waitPool(t *testing.T, pool *GoroutinePool, cond func(GoroutinePoolStats) bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond(pool.Stats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Unexpected pool stats: %+v", pool.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestServer_ConnPriority 测试过载时健康检查连接不受最大连接数限制，并排在等待中的普通连接之前
+func TestServer_ConnPriority(t *testing.T) {
+	bulk, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	health, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	healthAddr := health.Addr().String()
+
+	server := NewServerWithConfig(ServerConfig{
+		Workers:        1,
+		QueueSize:      2,
+		MaxConns:       3,
+		OverloadPolicy: OverloadReject,
+		Logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		ConnPriority: func(conn net.Conn) Priority {
+			if conn.LocalAddr().String() == healthAddr {
+				return PriorityHigh
+			}
+			return PriorityNormal
+		},
+	})
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+	go server.ServeListener(bulk)
+	go server.ServeListener(health)
+
+	// 先于 server.Close 关闭客户端连接，否则排队中的连接会一直等待读取
+	var conns []net.Conn
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	dial := func(addr string) net.Conn {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		conns = append(conns, conn)
+		return conn
+	}
+
+	// 第一个普通连接占用唯一的 worker，随后两个普通连接占满队列和连接数
+	request := `{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2},"id":1}`
+	first := dial(bulk.Addr().String())
+	if resp := roundTrip(t, first, request); !strings.Contains(resp, `"result":3`) {
+		t.Fatalf("Unexpected response: %s", resp)
+	}
+	dial(bulk.Addr().String())
+	dial(bulk.Addr().String())
+	waitPool(t, server.pool, func(s GoroutinePoolStats) bool { return s.Queued == 2 })
+
+	// 普通连接超过最大连接数被拒绝，健康检查连接仍然被接受
+	readOverloaded(t, dial(bulk.Addr().String()))
+	probe := dial(healthAddr)
+	waitPool(t, server.pool, func(s GoroutinePoolStats) bool { return s.QueuedHigh == 1 })
+
+	// worker 空闲后先处理健康检查连接，普通连接继续等待
+	first.Close()
+	if resp := roundTrip(t, probe, request); !strings.Contains(resp, `"result":3`) {
+		t.Fatalf("Unexpected response: %s", resp)
+	}
+	if stats := server.pool.Stats(); stats.Queued != 2 || stats.QueuedHigh != 0 {
+		t.Errorf("Expected normal connections to keep waiting, got %+v", stats)
+	}
+}
@@ -11,7 +11,28 @@ type Request struct {
 	Jsonrpc string          `json:"jsonrpc"` // 固定为 "2.0"
 	Method  string          `json:"method"`  // 要调用的方法名
 	Params  json.RawMessage `json:"params,omitempty"` // 方法参数（延迟解析）
-	ID      interface{}     `json:"id"` // 请求标识符
+	ID      interface{}     `json:"id,omitempty"` // 请求标识符，为 nil 时表示通知（不序列化 id）
+
+	// Timeout 扩展成员：客户端剩余的超时时间（毫秒），0 表示不限制
+	// 使用相对时间而不是绝对截止时间，避免客户端和服务端时钟偏差
+	Timeout int64 `json:"timeout,omitempty"`
+
+	// TraceParent / TraceState 扩展成员：W3C Trace Context 的 traceparent 和 tracestate
+	// 用于把调用方的链路信息传递给服务端，不认识这两个成员的对端会忽略它们
+	TraceParent string `json:"traceparent,omitempty"`
+	TraceState  string `json:"tracestate,omitempty"`
+
+	// Metadata 扩展成员：随调用传递的键值对，服务方法通过 MetadataFromContext 获取
+	Metadata Metadata `json:"metadata,omitempty"`
+
+	nullID bool // 解码时 id 成员存在且为 null（区别于缺省 id 的通知）
+}
+
+// IsNotification 判断请求是否为通知
+// 规范要求：没有 id 成员的请求为通知，服务端不得返回响应
+// 注意 "id":null 不是通知，仍需要返回响应
+func (r *Request) IsNotification() bool {
+	return r.ID == nil && !r.nullID
 }
 
 // Reset 重置 Request 对象状态，用于对象池复用
@@ -21,6 +42,11 @@ func (r *Request) Reset() {
 	r.Method = ""
 	r.Params = nil
 	r.ID = nil
+	r.Timeout = 0
+	r.TraceParent = ""
+	r.TraceState = ""
+	r.Metadata = nil
+	r.nullID = false
 }
 
 // Response 表示 JSON-RPC 2.0 响应消息
@@ -30,6 +56,9 @@ type Response struct {
 	Result  json.RawMessage `json:"result,omitempty"` // 调用结果（延迟解析）
 	Error   *Error          `json:"error,omitempty"` // 错误信息（如果有）
 	ID      interface{}     `json:"id"` // 对应的请求标识符
+
+	// Trailer 扩展成员：服务方法通过 SetTrailer 设置的响应元数据
+	Trailer Metadata `json:"trailer,omitempty"`
 }
 
 // Reset 重置 Response 对象状态，用于对象池复用
@@ -39,6 +68,7 @@ func (r *Response) Reset() {
 	r.Result = nil
 	r.Error = nil
 	r.ID = nil
+	r.Trailer = nil
 }
 
 // Error 表示 JSON-RPC 2.0 错误对象
@@ -52,3 +82,26 @@ type Error struct {
 func (e *Error) Error() string {
 	return e.Message
 }
+
+// RPCCode 返回错误码，实现 CodedError 接口
+func (e *Error) RPCCode() int {
+	return e.Code
+}
+
+// RPCData 返回附加数据，实现 DataError 接口
+func (e *Error) RPCData() interface{} {
+	return e.Data
+}
+
+// DecodeData 将附加数据解码到 v
+// 客户端收到的 Data 是通用的 JSON 值（map、slice 等），可以通过该方法转换为具体类型
+func (e *Error) DecodeData(v interface{}) error {
+	if e.Data == nil {
+		return nil
+	}
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,228 @@
+package rerpc
+
+import (
+	"context"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval 清理空闲客户端令牌桶的间隔
+const rateLimitSweepInterval = time.Minute
+
+// RateLimitData 限流错误的 data，客户端据此决定多久之后重试
+type RateLimitData struct {
+	RetryAfterMs int64 `json:"retry_after_ms"` // 建议的重试等待时间（毫秒）
+}
+
+// RateLimit 令牌桶限流参数
+// Rate <= 0 表示不限流
+type RateLimit struct {
+	Rate  float64 // 每秒补充的令牌数，即长期平均的每秒请求数
+	Burst int     // 桶容量，即允许的突发请求数（默认为 Rate 向上取整，至少为 1）
+}
+
+// burst 返回桶容量
+func (l RateLimit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, math.Ceil(l.Rate))
+}
+
+// RateLimiterConfig 服务端限流配置
+// 请求需要同时通过全局、方法和客户端三级限流，任意一级超限时请求被拒绝，且不消耗其他级别的令牌
+type RateLimiterConfig struct {
+	// Global 所有请求共享的限流
+	Global RateLimit
+
+	// Methods 按方法名（如 "Arith.Add"）配置的限流，同一方法的所有客户端共享
+	Methods map[string]RateLimit
+
+	// PerClient 每个客户端独立的限流
+	PerClient RateLimit
+
+	// ClientKey 返回请求所属客户端的标识（可选）
+	// 默认依次使用认证通过的调用方身份、已验证的客户端证书 CN、客户端 IP
+	// 返回空字符串时该请求不受客户端限流
+	ClientKey func(ctx context.Context, method string) string
+}
+
+// RateLimiter 服务端令牌桶限流器
+// 超限的请求收到 ErrCodeRateLimited 错误，data 中包含建议的重试等待时间
+type RateLimiter struct {
+	config RateLimiterConfig
+
+	mu        sync.Mutex
+	global    *tokenBucket
+	methods   map[string]*tokenBucket
+	clients   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter 创建限流器
+func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
+	if config.ClientKey == nil {
+		config.ClientKey = DefaultClientKey
+	}
+
+	now := time.Now()
+	l := &RateLimiter{
+		config:    config,
+		methods:   make(map[string]*tokenBucket, len(config.Methods)),
+		clients:   make(map[string]*tokenBucket),
+		lastSweep: now,
+	}
+	if config.Global.Rate > 0 {
+		l.global = newTokenBucket(config.Global, now)
+	}
+	for method, limit := range config.Methods {
+		if limit.Rate > 0 {
+			l.methods[method] = newTokenBucket(limit, now)
+		}
+	}
+	return l
+}
+
+// DefaultClientKey 默认的客户端标识
+// 依次使用认证通过的调用方身份、已验证的客户端证书 CN、客户端 IP（不含端口）
+func DefaultClientKey(ctx context.Context, method string) string {
+	if p, ok := PrincipalFromContext(ctx); ok && p.ID != "" {
+		return "principal:" + p.ID
+	}
+	peer, ok := PeerFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if cn := peer.CommonName(); cn != "" {
+		return "cn:" + cn
+	}
+	if peer.Addr == nil {
+		return ""
+	}
+	addr := peer.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return "ip:" + host
+	}
+	return "addr:" + addr
+}
+
+// Allow 判断请求是否可以执行
+// 允许时消耗一个令牌并返回 true；拒绝时返回建议的重试等待时间
+func (l *RateLimiter) Allow(ctx context.Context, method string) (time.Duration, bool) {
+	var key string
+	if l.config.PerClient.Rate > 0 {
+		key = l.config.ClientKey(ctx, method)
+	}
+
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buckets := make([]*tokenBucket, 0, 3)
+	if l.global != nil {
+		buckets = append(buckets, l.global)
+	}
+	if b, ok := l.methods[method]; ok {
+		buckets = append(buckets, b)
+	}
+	if key != "" {
+		l.sweep(now)
+		b, ok := l.clients[key]
+		if !ok {
+			b = newTokenBucket(l.config.PerClient, now)
+			l.clients[key] = b
+		}
+		buckets = append(buckets, b)
+	}
+
+	// 所有级别都有令牌时才消耗，避免被拒绝的请求占用其他级别的配额
+	var wait time.Duration
+	for _, b := range buckets {
+		if d := b.wait(now); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		return wait, false
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return 0, true
+}
+
+// sweep 定期删除已经补满的客户端令牌桶，避免大量短暂出现的客户端占用内存
+// 补满的桶与新建的桶等价，删除不影响限流结果
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	for key, b := range l.clients {
+		b.refill(now)
+		if b.tokens >= b.burst {
+			delete(l.clients, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// tokenBucket 令牌桶，由 RateLimiter 的互斥锁保护
+type tokenBucket struct {
+	rate   float64   // 每秒补充的令牌数
+	burst  float64   // 桶容量
+	tokens float64   // 当前令牌数
+	last   time.Time // 上次补充的时间
+}
+
+// newTokenBucket 创建装满令牌的桶
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	burst := limit.burst()
+	return &tokenBucket{rate: limit.Rate, burst: burst, tokens: burst, last: now}
+}
+
+// refill 按经过的时间补充令牌
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// wait 补充令牌后返回获得一个令牌还需要等待的时间，有令牌时返回 0
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// RetryAfter 从限流错误中获取服务端建议的重试等待时间
+// err 不是限流错误或没有携带等待时间时返回 false
+func RetryAfter(err error) (time.Duration, bool) {
+	rpcErr, ok := AsError(err)
+	if !ok || rpcErr.Code != ErrCodeRateLimited {
+		return 0, false
+	}
+
+	switch data := rpcErr.Data.(type) {
+	case RateLimitData:
+		return time.Duration(data.RetryAfterMs) * time.Millisecond, true
+	case map[string]interface{}:
+		// 客户端解码得到的 data
+		if ms, ok := data["retry_after_ms"].(float64); ok && ms >= 0 {
+			return time.Duration(ms) * time.Millisecond, true
+		}
+	}
+	return 0, false
+}
+
+// durationMillis 将时间转换为毫秒，不足 1 毫秒的部分向上取整
+func durationMillis(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
@@ -0,0 +1,161 @@
+package rerpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestRateLimiter 测试三级限流和令牌补充
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(RateLimiterConfig{
+		Global:    RateLimit{Rate: 1000, Burst: 4},
+		Methods:   map[string]RateLimit{"Arith.Slow": {Rate: 10, Burst: 1}},
+		PerClient: RateLimit{Rate: 10, Burst: 2},
+	})
+
+	alice := contextWithPrincipal(context.Background(), &Principal{ID: "alice"})
+	bob := contextWithPrincipal(context.Background(), &Principal{ID: "bob"})
+
+	// 方法限流：第二次调用被拒绝，不消耗客户端的令牌
+	if _, ok := limiter.Allow(alice, "Arith.Slow"); !ok {
+		t.Fatal("Expected first call to be allowed")
+	}
+	wait, ok := limiter.Allow(alice, "Arith.Slow")
+	if ok {
+		t.Fatal("Expected method limit to reject")
+	}
+	if wait <= 0 || wait > 100*time.Millisecond {
+		t.Errorf("Unexpected retry after: %v", wait)
+	}
+	if _, ok := limiter.Allow(alice, "Arith.Add"); !ok {
+		t.Fatal("Expected rejected call not to consume client token")
+	}
+
+	// 客户端限流：alice 的令牌用完，bob 不受影响
+	if _, ok := limiter.Allow(alice, "Arith.Add"); ok {
+		t.Error("Expected client limit to reject")
+	}
+	if _, ok := limiter.Allow(bob, "Arith.Add"); !ok {
+		t.Error("Expected other client to be allowed")
+	}
+
+	// 令牌按速率补充
+	time.Sleep(110 * time.Millisecond)
+	if _, ok := limiter.Allow(alice, "Arith.Add"); !ok {
+		t.Error("Expected token to be refilled")
+	}
+}
+
+// TestRateLimiterGlobal 测试全局限流和不受客户端限流的请求
+func TestRateLimiterGlobal(t *testing.T) {
+	limiter := NewRateLimiter(RateLimiterConfig{
+		Global:    RateLimit{Rate: 1},
+		PerClient: RateLimit{Rate: 1},
+		ClientKey: func(ctx context.Context, method string) string { return "" },
+	})
+
+	if _, ok := limiter.Allow(context.Background(), "A.B"); !ok {
+		t.Fatal("Expected first call to be allowed")
+	}
+	wait, ok := limiter.Allow(context.Background(), "C.D")
+	if ok {
+		t.Fatal("Expected global limit to reject")
+	}
+	if wait <= 900*time.Millisecond || wait > time.Second {
+		t.Errorf("Unexpected retry after: %v", wait)
+	}
+	if len(limiter.clients) != 0 {
+		t.Errorf("Expected no client buckets, got %d", len(limiter.clients))
+	}
+}
+
+// TestDefaultClientKey 测试默认客户端标识的优先级
+func TestDefaultClientKey(t *testing.T) {
+	ctx := contextWithPeer(context.Background(), &Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.8"), Port: 51234}})
+	if key := DefaultClientKey(ctx, "A.B"); key != "ip:10.0.0.8" {
+		t.Errorf("Expected ip key, got %q", key)
+	}
+
+	ctx = contextWithPrincipal(ctx, &Principal{ID: "alice"})
+	if key := DefaultClientKey(ctx, "A.B"); key != "principal:alice" {
+		t.Errorf("Expected principal key, got %q", key)
+	}
+
+	if key := DefaultClientKey(context.Background(), "A.B"); key != "" {
+		t.Errorf("Expected empty key, got %q", key)
+	}
+}
+
+// TestRateLimitedCall 测试限流错误的 retry-after 提示和客户端的重试
+func TestRateLimitedCall(t *testing.T) {
+	server := NewServerWithConfig(ServerConfig{
+		Workers:     4,
+		RateLimiter: NewRateLimiter(RateLimiterConfig{PerClient: RateLimit{Rate: 5, Burst: 1}}),
+	})
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	// 不重试的客户端收到限流错误
+	client, err := NewClient(ClientConfig{Address: listener.Addr().String(), MaxRetries: 0})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	var reply EchoReply
+	if err := client.Call(ctx, "TestService.Echo", &EchoArgs{Message: "a"}, &reply); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	err = client.Call(ctx, "TestService.Echo", &EchoArgs{Message: "b"}, &reply)
+	rpcErr, ok := AsError(err)
+	if !ok || rpcErr.Code != ErrCodeRateLimited {
+		t.Fatalf("Expected rate limited error, got %v", err)
+	}
+	wait, ok := RetryAfter(err)
+	if !ok || wait <= 0 || wait > 200*time.Millisecond {
+		t.Errorf("Unexpected retry after: %v, %v", wait, ok)
+	}
+	if stats := server.Stats(); stats.RateLimited != 1 {
+		t.Errorf("Expected 1 rate limited request, got %d", stats.RateLimited)
+	}
+
+	// 重试的客户端按提示等待后成功
+	retrying, err := NewClient(ClientConfig{Address: listener.Addr().String(), MaxRetries: 2, RetryDelay: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer retrying.Close()
+
+	start := time.Now()
+	if err := retrying.Call(ctx, "TestService.Echo", &EchoArgs{Message: "c"}, &reply); err != nil {
+		t.Fatalf("Expected retry to succeed, got %v", err)
+	}
+	if reply.Message != "c" {
+		t.Errorf("Expected echo c, got %q", reply.Message)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected client to wait for retry after, took %v", elapsed)
+	}
+
+	// 提示的等待时间超过截止时间时不再重试
+	if err := retrying.Call(ctx, "TestService.Echo", &EchoArgs{Message: "d"}, &reply); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	err = retrying.Call(short, "TestService.Echo", &EchoArgs{Message: "e"}, &reply)
+	if _, ok := RetryAfter(err); !ok {
+		t.Errorf("Expected rate limited error before deadline, got %v", err)
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"runtime/debug"
+	"strings"
 	"sync"
 	"unicode"
 	"unicode/utf8"
@@ -17,6 +17,9 @@ import (
 type ServiceRegistry struct {
 	services map[string]*serviceType // 服务名称 -> 服务类型
 	mu       sync.RWMutex            // 读写锁，读多写少场景优化
+
+	panicHandler PanicHandler // 服务方法 panic 时的回调，可以为 nil
+	redactPanics bool         // 是否隐藏返回给客户端的 panic 详情
 }
 
 // NewServiceRegistry 创建新的服务注册表
@@ -41,11 +44,34 @@ type methodType struct {
 	method    reflect.Method // 方法的反射信息
 	ArgType   reflect.Type   // 参数类型
 	ReplyType reflect.Type   // 返回值类型
+
+	// 位置参数映射：params 为数组时，第 i 个元素写入 ArgType 的第 paramFields[i] 个字段
+	// 仅在 positional 为 true 时有效
+	paramFields []positionalField
+	positional  bool // ArgType 是否支持位置参数（结构体且 *ArgType 没有实现 json.Unmarshaler）
+}
+
+// unmarshalerType json.Unmarshaler 接口的反射类型
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// positionalField 位置参数对应的结构体字段
+type positionalField struct {
+	index int    // 字段下标
+	name  string // 字段的 JSON 名称，用于错误报告
+}
+
+// ParamError 描述单个位置参数的错误
+// 位置参数解析失败时，所有出错的参数会作为 Invalid params 错误的 data 返回
+type ParamError struct {
+	Position int    `json:"position"` // 参数在数组中的位置（从 0 开始）
+	Field    string `json:"field"`    // 对应的字段名
+	Message  string `json:"error"`    // 错误描述
 }
 
 // Register 注册一个服务实例
 // 使用反射提取服务的所有导出方法，并验证方法签名
 // 方法签名必须符合：func(ctx context.Context, args *T, reply *R) error
+// 当 T 为结构体时，params 既可以是对象（按字段名），也可以是数组（按字段声明顺序）
 func (r *ServiceRegistry) Register(service interface{}) error {
 	return r.register(service, "", false)
 }
@@ -98,10 +124,13 @@ func (r *ServiceRegistry) register(service interface{}, name string, useName boo
 		}
 
 		// 缓存方法信息
+		argType := mtype.In(2).Elem() // 第2个参数是 *T，取 Elem() 得到 T
 		s.methods[method.Name] = &methodType{
-			method:    method,
-			ArgType:   mtype.In(2).Elem(), // 第2个参数是 *T，取 Elem() 得到 T
-			ReplyType: mtype.In(3).Elem(), // 第3个参数是 *R，取 Elem() 得到 R
+			method:      method,
+			ArgType:     argType,
+			ReplyType:   mtype.In(3).Elem(), // 第3个参数是 *R，取 Elem() 得到 R
+			paramFields: positionalFields(argType),
+			positional:  supportsPositional(argType),
 		}
 	}
 
@@ -164,6 +193,43 @@ func (r *ServiceRegistry) validateMethod(mname string, mtype reflect.Type) error
 	return nil
 }
 
+// supportsPositional 判断参数类型是否按位置参数映射数组 params
+// 自定义了 UnmarshalJSON 的类型自行解析数组，不做映射
+func supportsPositional(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && !reflect.PointerTo(typ).Implements(unmarshalerType)
+}
+
+// positionalFields 提取结构体参数的位置参数映射
+// 按声明顺序收集导出的非匿名字段，跳过 json:"-" 的字段
+// 性能优化：在注册时计算一次，调用时直接按下标访问字段
+func positionalFields(typ reflect.Type) []positionalField {
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := make([]positionalField, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		fields = append(fields, positionalField{index: i, name: name})
+	}
+	return fields
+}
+
 // isExported 判断名称是否导出（首字母大写）
 func isExported(name string) bool {
 	r, _ := utf8.DecodeRuneInString(name)
@@ -202,10 +268,10 @@ func (r *ServiceRegistry) Call(ctx context.Context, serviceName, methodName stri
 // 包含 panic 恢复机制，确保服务稳定性
 func (r *ServiceRegistry) call(ctx context.Context, service *serviceType, method *methodType, argsData json.RawMessage) (result interface{}, err error) {
 	// Panic 恢复
-	// 捕获方法执行中的 panic，转换为错误返回
+	// 捕获方法执行中的 panic，上报后转换为错误返回
 	defer func() {
-		if r := recover(); r != nil {
-			err = NewInternalError(fmt.Sprintf("panic: %v\nstack: %s", r, debug.Stack()))
+		if v := recover(); v != nil {
+			err = r.panicError(ctx, service.name+"."+method.method.Name, v)
 		}
 	}()
 
@@ -216,7 +282,12 @@ func (r *ServiceRegistry) call(ctx context.Context, service *serviceType, method
 	// 反序列化参数
 	// 处理参数解析错误
 	if len(argsData) > 0 {
-		if err := json.Unmarshal(argsData, argv.Interface()); err != nil {
+		if firstByte(argsData) == '[' && method.positional {
+			// 位置参数：数组元素按声明顺序映射到结构体字段
+			if rpcErr := method.unmarshalPositional(argsData, argv.Elem()); rpcErr != nil {
+				return nil, rpcErr
+			}
+		} else if err := json.Unmarshal(argsData, argv.Interface()); err != nil {
 			return nil, NewInvalidParamsError(fmt.Sprintf("failed to unmarshal args: %v", err))
 		}
 	}
@@ -235,9 +306,10 @@ func (r *ServiceRegistry) call(ctx context.Context, service *serviceType, method
 	})
 
 	// 检查返回的错误
+	// 业务错误码（*Error、CodedError）原样传递，其他错误转换为 Internal error
 	errInter := returnValues[0].Interface()
 	if errInter != nil {
-		return nil, NewInternalError(errInter.(error).Error())
+		return nil, toRPCError(errInter.(error))
 	}
 
 	// 返回结果
@@ -245,6 +317,50 @@ func (r *ServiceRegistry) call(ctx context.Context, service *serviceType, method
 	return replyv.Interface(), nil
 }
 
+// unmarshalPositional 将位置参数数组解析到结构体字段
+// 缺少的参数保持零值；多余的参数或类型错误返回 Invalid params，
+// 类型错误以 []ParamError 的形式逐个报告
+func (m *methodType) unmarshalPositional(data json.RawMessage, argv reflect.Value) *Error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return NewInvalidParamsError(fmt.Sprintf("failed to unmarshal args: %v", err))
+	}
+
+	if len(items) > len(m.paramFields) {
+		return NewInvalidParamsError(fmt.Sprintf("too many params: got %d, want at most %d", len(items), len(m.paramFields)))
+	}
+
+	var paramErrs []ParamError
+	for i, item := range items {
+		field := m.paramFields[i]
+		if err := json.Unmarshal(item, argv.Field(field.index).Addr().Interface()); err != nil {
+			paramErrs = append(paramErrs, ParamError{
+				Position: i,
+				Field:    field.name,
+				Message:  err.Error(),
+			})
+		}
+	}
+
+	if len(paramErrs) > 0 {
+		return NewInvalidParamsError(paramErrs)
+	}
+	return nil
+}
+
+// hasMethod 判断方法是否已注册
+func (r *ServiceRegistry) hasMethod(serviceName, methodName string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	service, ok := r.services[serviceName]
+	if !ok {
+		return false
+	}
+	_, ok = service.methods[methodName]
+	return ok
+}
+
 // GetService 获取已注册的服务信息（用于调试）
 func (r *ServiceRegistry) GetService(name string) (methods []string, exists bool) {
 	r.mu.RLock()
@@ -323,6 +323,73 @@ func TestServiceRegistry_CallWithError(t *testing.T) {
 	}
 }
 
+// insufficientFundsError 实现 CodedError 和 DataError 的业务错误
+type insufficientFundsError struct {
+	Balance int
+}
+
+func (e *insufficientFundsError) Error() string        { return "insufficient funds" }
+func (e *insufficientFundsError) RPCCode() int         { return 1002 }
+func (e *insufficientFundsError) RPCData() interface{} { return map[string]int{"balance": e.Balance} }
+
+// AppErrorService 返回业务错误的服务
+type AppErrorService struct{}
+
+type AppErrorArgs struct {
+	Kind string `json:"kind"`
+}
+
+func (s *AppErrorService) Fail(ctx context.Context, args *AppErrorArgs, reply *ArithReply) error {
+	switch args.Kind {
+	case "rpc":
+		return NewError(1001, "insufficient funds", "details")
+	case "wrapped":
+		return fmt.Errorf("withdraw: %w", NewError(1001, "insufficient funds", "details"))
+	case "coded":
+		return fmt.Errorf("withdraw: %w", &insufficientFundsError{Balance: 10})
+	default:
+		return fmt.Errorf("plain error")
+	}
+}
+
+// TestServiceRegistry_CallWithAppError 测试业务错误码透传
+func TestServiceRegistry_CallWithAppError(t *testing.T) {
+	registry := NewServiceRegistry()
+	if err := registry.Register(new(AppErrorService)); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	tests := []struct {
+		kind        string
+		wantCode    int
+		wantMessage string
+		wantData    interface{}
+	}{
+		{"rpc", 1001, "insufficient funds", "details"},
+		{"wrapped", 1001, "insufficient funds", "details"},
+		{"coded", 1002, "insufficient funds", map[string]int{"balance": 10}},
+		{"plain", ErrCodeInternal, ErrMsgInternal, "plain error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			argsData, _ := json.Marshal(&AppErrorArgs{Kind: tt.kind})
+			_, err := registry.Call(context.Background(), "AppErrorService", "Fail", argsData)
+
+			rpcErr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("Call() error type = %T, want *Error", err)
+			}
+			if rpcErr.Code != tt.wantCode || rpcErr.Message != tt.wantMessage {
+				t.Errorf("Call() error = %d %q, want %d %q", rpcErr.Code, rpcErr.Message, tt.wantCode, tt.wantMessage)
+			}
+			if fmt.Sprint(rpcErr.Data) != fmt.Sprint(tt.wantData) {
+				t.Errorf("Call() error data = %v, want %v", rpcErr.Data, tt.wantData)
+			}
+		})
+	}
+}
+
 // TestServiceRegistry_CallWithInvalidParams 测试无效参数
 func TestServiceRegistry_CallWithInvalidParams(t *testing.T) {
 	registry := NewServiceRegistry()
@@ -350,6 +417,118 @@ func TestServiceRegistry_CallWithInvalidParams(t *testing.T) {
 	}
 }
 
+// SumArgs 带有跳过字段的参数，用于测试位置参数
+type SumArgs struct {
+	Values  []int  `json:"values"`
+	Ignored string `json:"-"`
+	Scale   int
+}
+
+// SumService 用于测试位置参数
+type SumService struct{}
+
+func (s *SumService) Sum(ctx context.Context, args *SumArgs, reply *ArithReply) error {
+	for _, v := range args.Values {
+		reply.Result += v
+	}
+	if args.Scale != 0 {
+		reply.Result *= args.Scale
+	}
+	return nil
+}
+
+func (s *SumService) SumSlice(ctx context.Context, args *[]int, reply *ArithReply) error {
+	for _, v := range *args {
+		reply.Result += v
+	}
+	return nil
+}
+
+// PairArgs 字段未导出、自行解析 [a, b] 的参数，用于测试位置参数不覆盖 json.Unmarshaler
+type PairArgs struct {
+	a, b int
+}
+
+func (p *PairArgs) UnmarshalJSON(data []byte) error {
+	var pair [2]int
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	p.a, p.b = pair[0], pair[1]
+	return nil
+}
+
+func (s *SumService) SumPair(ctx context.Context, args *PairArgs, reply *ArithReply) error {
+	reply.Result = args.a + args.b
+	return nil
+}
+
+// TestServiceRegistry_CallWithPositionalParams 测试位置参数
+func TestServiceRegistry_CallWithPositionalParams(t *testing.T) {
+	registry := NewServiceRegistry()
+	if err := registry.Register(new(ArithService)); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := registry.Register(new(SumService)); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		serviceName string
+		methodName  string
+		params      string
+		wantResult  int
+	}{
+		{"按字段顺序映射", "ArithService", "Add", `[1, 2]`, 3},
+		{"缺少的参数为零值", "ArithService", "Add", `[5]`, 5},
+		{"跳过 json:\"-\" 字段", "SumService", "Sum", `[[1, 2, 3], 2]`, 12},
+		{"切片参数直接接收数组", "SumService", "SumSlice", `[1, 2, 3]`, 6},
+		{"自定义 UnmarshalJSON 自行解析数组", "SumService", "SumPair", `[4, 5]`, 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := registry.Call(context.Background(), tt.serviceName, tt.methodName, json.RawMessage(tt.params))
+			if err != nil {
+				t.Fatalf("Call() error = %v", err)
+			}
+			if reply := result.(*ArithReply); reply.Result != tt.wantResult {
+				t.Errorf("Call() result = %d, want %d", reply.Result, tt.wantResult)
+			}
+		})
+	}
+}
+
+// TestServiceRegistry_CallWithInvalidPositionalParams 测试位置参数错误
+func TestServiceRegistry_CallWithInvalidPositionalParams(t *testing.T) {
+	registry := NewServiceRegistry()
+	if err := registry.Register(new(ArithService)); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	// 参数过多
+	_, err := registry.Call(context.Background(), "ArithService", "Add", json.RawMessage(`[1, 2, 3]`))
+	if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != ErrCodeInvalidParams {
+		t.Fatalf("Call() error = %v, want Invalid params", err)
+	}
+
+	// 类型错误按位置逐个报告
+	_, err = registry.Call(context.Background(), "ArithService", "Add", json.RawMessage(`["x", 2]`))
+	rpcErr, ok := err.(*Error)
+	if !ok || rpcErr.Code != ErrCodeInvalidParams {
+		t.Fatalf("Call() error = %v, want Invalid params", err)
+	}
+
+	paramErrs, ok := rpcErr.Data.([]ParamError)
+	if !ok || len(paramErrs) != 1 {
+		t.Fatalf("Call() error data = %#v, want one ParamError", rpcErr.Data)
+	}
+	if paramErrs[0].Position != 0 || paramErrs[0].Field != "a" {
+		t.Errorf("ParamError = %+v, want position 0 field a", paramErrs[0])
+	}
+}
+
 // TestServiceRegistry_CallWithPanic 测试 panic 恢复
 func TestServiceRegistry_CallWithPanic(t *testing.T) {
 	registry := NewServiceRegistry()
@@ -1,17 +1,36 @@
 package rerpc
 
 import (
-	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+// defaultConnConcurrency 单个连接上同时处理的最大请求数（默认值）
+const defaultConnConcurrency = 64
+
+// ServerConfig 的默认值
+const (
+	defaultWorkers      = 100              // 协程池工作协程数量
+	defaultIdleTimeout  = 5 * time.Minute  // 等待下一条请求的超时时间
+	defaultReadTimeout  = 30 * time.Second // 读完一条请求的超时时间
+	defaultWriteTimeout = 30 * time.Second // 写出一条响应的超时时间
+	defaultBufferSize   = 32 * 1024        // 连接读写缓冲大小
+)
+
+// ErrServerClosed 表示服务器已关闭，ServeListener 不再接受新的监听器
+var ErrServerClosed = errors.New("server is closed")
+
 // Server RPC 服务器
 // 集成 ServiceRegistry、GoroutinePool 和 Codec
 // 性能优化：
@@ -19,29 +38,278 @@ import (
 // 2. 使用对象池复用 Request/Response 对象
 // 3. 使用 bufio 减少系统调用
 type Server struct {
-	registry *ServiceRegistry // 服务注册表
-	pool     *GoroutinePool   // 协程池
-	codec    Codec            // 编解码器
-	listener net.Listener     // TCP 监听器
-	mu       sync.Mutex       // 保护 listener 和 shutdown 状态
-	shutdown int32            // 关闭标志（原子操作）
-	wg       sync.WaitGroup   // 等待所有连接处理完成
+	registry  *ServiceRegistry // 服务注册表
+	pool      *GoroutinePool   // 协程池
+	codec     Codec            // 编解码器
+	listeners []net.Listener   // 正在服务的监听器（按加入顺序）
+	mu        sync.Mutex       // 保护 listeners 和 shutdown 状态
+	shutdown  int32            // 关闭标志（原子操作）
+	wg        sync.WaitGroup   // 等待所有连接处理完成
+	tlsConfig *tls.Config      // TLS 配置，为 nil 时不启用 TLS
+
+	connConcurrency int  // 单个连接上同时处理的最大请求数
+	strictOrdering  bool // 是否按请求顺序写回响应
+	maxConns        int  // 最大并发连接数，0 表示不限制
+
+	overloadPolicy OverloadPolicy // 协程池队列已满时对新连接的处理策略
+	maxRequests    int            // 最大并发请求数，0 表示不限制
+
+	priorities       atomic.Value                 // 方法优先级 map[string]Priority（写时复制）
+	priorityMetadata bool                         // 是否使用请求元数据中的优先级
+	connPriority     func(conn net.Conn) Priority // 新连接的优先级，为 nil 时均为普通优先级
+
+	maxRequestBytes int           // 单条请求消息的最大字节数
+	idleTimeout     time.Duration // 连接空闲超时
+	readTimeout     time.Duration // 单条请求的读取超时
+	writeTimeout    time.Duration // 单条响应的写入超时
+	readBufferSize  int           // 连接读缓冲大小
+	writeBufferSize int           // 连接写缓冲大小
+
+	logger    *slog.Logger // 日志
+	accessLog bool         // 是否记录访问日志
+	metrics   Metrics      // 指标，为 nil 时不记录
+	tracer    Tracer       // 链路追踪，为 nil 时只传递链路信息
+	hooks     ServerHooks  // 连接生命周期回调
+
+	checkOrigin func(r *http.Request) bool // WebSocket 握手的 Origin 校验
+
+	authenticator Authenticator // 认证器，为 nil 时不认证
+	rateLimiter   *RateLimiter  // 限流器，为 nil 时不限流
+
+	middlewares []Middleware // 服务端拦截器
+	handler     atomic.Value // 包装了拦截器的 Handler
+
+	// 统计信息（原子操作）
+	activeConns    int64  // 当前连接数（TCP 和 WebSocket）
+	activeRequests int64  // 正在执行的请求数
+	cancelled      uint64 // 被 $/cancelRequest 取消的请求数
+	rateLimited    uint64 // 被限流拒绝的请求数
+
+	rejectedConns    uint64 // 因过载或超过最大连接数被拒绝的连接数
+	rejectedRequests uint64 // 因超过最大并发请求数被拒绝的请求数
+}
+
+// ServerConfig 服务器配置
+// 所有字段都是可选的，零值使用默认值
+type ServerConfig struct {
+	Workers   int // 协程池最大工作协程数量（默认 100），用于限制并发连接处理数
+	QueueSize int // 协程池任务队列大小（默认 Workers 的 2 倍）
+	MaxConns  int // 最大并发连接数（TCP 和 WebSocket），超过时新连接收到过载错误后被关闭；0 表示不限制
+
+	// MinWorkers 协程池最小工作协程数量，小于 Workers 时协程池在两者之间弹性伸缩
+	// 默认等于 Workers，即启动时创建全部工作协程且不回收
+	MinWorkers int
+	// WorkerIdleTimeout 弹性协程池中超过 MinWorkers 的工作协程空闲多久后退出（默认 1 分钟）
+	WorkerIdleTimeout time.Duration
+
+	// OverloadPolicy 协程池队列已满时对新连接的处理策略（默认 OverloadBlock）
+	OverloadPolicy OverloadPolicy
+
+	// MaxRequests 所有连接和传输上同时执行的最大请求数，超过时请求立即返回 ErrCodeServerOverloaded 错误；0 表示不限制
+	// 高优先级请求不受此限制
+	MaxRequests int
+
+	// MethodPriorities 方法优先级，键为 "Service.Method"，也可以通过 SetMethodPriority 设置
+	// 高优先级请求（如健康检查）不占用连接的并发名额，不受 MaxRequests 限制
+	MethodPriorities map[string]Priority
+	// PriorityMetadata 是否使用请求元数据 MetadataPriority 中客户端声明的优先级
+	// 只应在客户端可信（如已认证的内部服务）时开启
+	PriorityMetadata bool
+	// PriorityWeight 协程池连续执行多少个高优先级任务后执行一个普通任务，0 表示严格优先
+	PriorityWeight int
+	// ConnPriority 新的 TCP 连接的优先级，在读取任何请求之前根据连接判断，
+	// 例如按监听地址区分健康检查端口，或按来源地址区分编排系统的探针
+	// 高优先级连接不受 MaxConns 限制，进入协程池的高优先级队列，排在等待中的普通连接之前
+	ConnPriority func(conn net.Conn) Priority
+
+	// PanicHandler 处理请求（认证、限流、链路追踪、拦截器和服务方法）或协程池任务 panic 时的回调，接收 panic 的值、调用栈、方法名和请求 ID
+	// 为 nil 时协程池任务的 panic 记录到 Logger
+	PanicHandler PanicHandler
+	// RedactPanics 是否隐藏返回给客户端的 panic 详情
+	// 默认内部错误的 data 包含 panic 的值和调用栈；开启后只包含事件 ID（PanicData），
+	// 未设置 PanicHandler 时 panic 详情连同事件 ID 记录到 Logger
+	RedactPanics bool
+
+	IdleTimeout  time.Duration // 连接在两次请求之间的最长空闲时间（默认 5 分钟）
+	ReadTimeout  time.Duration // 从请求的第一个字节到达起读完整条请求的时间（默认 30 秒）
+	WriteTimeout time.Duration // 写出一条响应或推送通知的超时时间（默认 30 秒）
+
+	ReadBufferSize  int // TCP 连接读缓冲大小（默认 32KB）
+	WriteBufferSize int // TCP 和 WebSocket 连接写缓冲大小（默认 32KB）
+	MaxRequestBytes int // 单条请求消息的最大字节数（默认 32MB）
+
+	ConnConcurrency int  // 单个连接上同时处理的最大请求数（默认 64）
+	StrictOrdering  bool // 是否按请求顺序写回响应
+
+	// TLSConfig TLS 配置（可选），为 nil 时不启用 TLS
+	TLSConfig *tls.Config
+
+	// Codec 编解码器（默认使用 JSONCodec 和默认对象池）
+	Codec Codec
+
+	// Logger 日志（默认 slog.Default()）
+	// 连接错误、协程池中任务的 panic 和访问日志都写入该 Logger
+	Logger *slog.Logger
+
+	// AccessLog 是否为每个请求记录一条访问日志
+	// 包含方法名、请求 ID、耗时、客户端地址和错误码，成功为 Info 级别，失败为 Warn 级别
+	AccessLog bool
+
+	// Metrics 指标（可选），如 NewPrometheusMetrics()
+	// 实现了 GoroutinePoolCollector 时自动注册服务端的协程池
+	Metrics Metrics
+
+	// Tracer 链路追踪（可选），为每个请求创建一个服务端 span，父 span 为请求中 traceparent 对应的调用方 span
+	// 未配置时请求中的链路信息仍会保存到服务方法的 ctx，服务方法中发起的调用会继续传递
+	Tracer Tracer
+
+	// Hooks 连接生命周期回调（可选）
+	Hooks ServerHooks
+
+	// CheckOrigin 校验 WebSocket 握手请求的 Origin 头，返回 false 时以 403 拒绝握手
+	// 默认只允许没有 Origin 头（非浏览器客户端）或 Origin 与 Host 相同的请求，防止跨站 WebSocket 劫持
+	CheckOrigin func(r *http.Request) bool
+
+	// Authenticator 认证器（可选），如 NewBearerAuthenticator、NewHMACAuthenticator
+	// 在拦截器链之前验证每个请求，未通过的请求返回 ErrCodeUnauthenticated 错误，
+	// 通过时服务方法和拦截器可以通过 PrincipalFromContext 获取调用方身份
+	Authenticator Authenticator
+
+	// RateLimiter 限流器（可选），在认证之后、拦截器链之前检查每个请求
+	// 超限的请求返回 ErrCodeRateLimited 错误，data 中包含建议的重试等待时间
+	RateLimiter *RateLimiter
+}
+
+// ServerHooks 服务器连接生命周期回调
+// 回调在处理连接的协程中同步调用，不应长时间阻塞
+type ServerHooks struct {
+	// OnConnect 连接建立（TLS 握手完成）后调用
+	// 返回错误时连接被直接关闭，可以用于按地址或证书拒绝连接
+	OnConnect func(peer *Peer) error
+
+	// OnDisconnect 连接上的请求全部处理完成、连接即将关闭时调用（OnConnect 拒绝的连接不会调用）
+	OnDisconnect func(peer *Peer)
 }
 
 // NewServer 创建一个新的 RPC 服务器
 // workers: 协程池的工作协程数量，用于限制并发连接处理数
 // 如果 workers <= 0，默认使用 100
+// 其他配置使用默认值，需要调整时使用 NewServerWithConfig
 func NewServer(workers int) *Server {
-	if workers <= 0 {
-		workers = 100
+	return NewServerWithConfig(ServerConfig{Workers: workers})
+}
+
+// NewServerWithConfig 使用指定配置创建 RPC 服务器
+func NewServerWithConfig(config ServerConfig) *Server {
+	// 设置默认值
+	if config.Workers <= 0 {
+		config.Workers = defaultWorkers
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = config.Workers * 2 // 队列大小为 workers 的 2 倍
+	}
+	if config.MinWorkers <= 0 || config.MinWorkers > config.Workers {
+		config.MinWorkers = config.Workers
+	}
+	if config.MaxConns < 0 {
+		config.MaxConns = 0
+	}
+	if config.MaxRequests < 0 {
+		config.MaxRequests = 0
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaultIdleTimeout
+	}
+	if config.ReadTimeout <= 0 {
+		config.ReadTimeout = defaultReadTimeout
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = defaultWriteTimeout
+	}
+	if config.ReadBufferSize <= 0 {
+		config.ReadBufferSize = defaultBufferSize
+	}
+	if config.WriteBufferSize <= 0 {
+		config.WriteBufferSize = defaultBufferSize
+	}
+	if config.MaxRequestBytes <= 0 {
+		config.MaxRequestBytes = defaultMaxMessageSize
+	}
+	if config.ConnConcurrency <= 0 {
+		config.ConnConcurrency = defaultConnConcurrency
+	}
+	if config.Codec == nil {
+		config.Codec = NewJSONCodec(nil) // 使用默认对象池
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
 	}
+	if config.CheckOrigin == nil {
+		config.CheckOrigin = sameOrigin
+	}
+
+	pool := NewGoroutinePoolWithConfig(GoroutinePoolConfig{
+		MinWorkers:     config.MinWorkers,
+		MaxWorkers:     config.Workers,
+		QueueSize:      config.QueueSize,
+		IdleTimeout:    config.WorkerIdleTimeout,
+		PriorityWeight: config.PriorityWeight,
+	})
+
+	s := &Server{
+		registry:  NewServiceRegistry(),
+		pool:      pool,
+		codec:     config.Codec,
+		shutdown:  0,
+		tlsConfig: config.TLSConfig,
+
+		connConcurrency: config.ConnConcurrency,
+		strictOrdering:  config.StrictOrdering,
+		maxConns:        config.MaxConns,
+
+		overloadPolicy: config.OverloadPolicy,
+		maxRequests:    config.MaxRequests,
+
+		priorityMetadata: config.PriorityMetadata,
+		connPriority:     config.ConnPriority,
+
+		maxRequestBytes: config.MaxRequestBytes,
+		idleTimeout:     config.IdleTimeout,
+		readTimeout:     config.ReadTimeout,
+		writeTimeout:    config.WriteTimeout,
+		readBufferSize:  config.ReadBufferSize,
+		writeBufferSize: config.WriteBufferSize,
+
+		logger:    config.Logger,
+		accessLog: config.AccessLog,
+		metrics:   config.Metrics,
+		tracer:    config.Tracer,
+		hooks:     config.Hooks,
+
+		checkOrigin: config.CheckOrigin,
 
-	return &Server{
-		registry: NewServiceRegistry(),
-		pool:     NewGoroutinePool(workers, workers*2), // 队列大小为 workers 的 2 倍
-		codec:    NewJSONCodec(nil),                    // 使用默认对象池
-		shutdown: 0,
+		authenticator: config.Authenticator,
+		rateLimiter:   config.RateLimiter,
 	}
+	s.pool.SetLogger(config.Logger)
+	s.pool.SetPanicHandler(config.PanicHandler)
+	if config.PanicHandler != nil {
+		s.registry.SetPanicHandler(config.PanicHandler)
+	} else if config.RedactPanics {
+		s.registry.SetPanicHandler(s.logPanic)
+	}
+	s.registry.SetRedactPanics(config.RedactPanics)
+	if collector, ok := config.Metrics.(GoroutinePoolCollector); ok {
+		collector.RegisterGoroutinePool("server", s.pool)
+	}
+	s.handler.Store(Handler(s.invoke))
+
+	priorities := make(map[string]Priority, len(config.MethodPriorities))
+	for method, priority := range config.MethodPriorities {
+		priorities[method] = priority
+	}
+	s.priorities.Store(priorities)
+	return s
 }
 
 // Register 注册一个服务实例
@@ -59,33 +327,128 @@ func (s *Server) RegisterName(name string, service interface{}) error {
 	return s.registry.RegisterName(name, service)
 }
 
+// SetConnConcurrency 设置单个连接上同时处理的最大请求数
+// 客户端在同一连接上管道化发送的请求会并发执行，慢请求不会阻塞后续请求
+// n <= 0 时使用默认值 64；n == 1 时同一连接上的请求逐个执行
+// 只对之后建立的连接生效
+func (s *Server) SetConnConcurrency(n int) {
+	if n <= 0 {
+		n = defaultConnConcurrency
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connConcurrency = n
+}
+
+// SetStrictOrdering 设置是否按请求到达的顺序写回响应
+// 默认按完成顺序写回（客户端按 ID 匹配响应）；不支持乱序响应的客户端可以开启
+// 开启后请求仍然并发执行，先完成的响应会等待之前的响应写出
+// 只对之后建立的连接生效
+func (s *Server) SetStrictOrdering(strict bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strictOrdering = strict
+}
+
+// SetMaxRequestBytes 设置单条请求消息（包括批量请求）的最大字节数
+// 超过限制的请求会收到 Invalid Request 错误响应，随后连接被关闭；HTTP 传输返回 413
+// n <= 0 时使用默认值 32MB
+// 只对之后建立的连接生效
+func (s *Server) SetMaxRequestBytes(n int) {
+	if n <= 0 {
+		n = defaultMaxMessageSize
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxRequestBytes = n
+}
+
+// SetIdleTimeout 设置连接空闲超时，连接在该时间内没有发送新请求时被关闭
+// 新连接等待第一条请求同样使用空闲超时（客户端连接池会提前建立连接）
+// d <= 0 时使用默认值 5 分钟
+// 只对之后建立的连接生效
+func (s *Server) SetIdleTimeout(d time.Duration) {
+	if d <= 0 {
+		d = defaultIdleTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idleTimeout = d
+}
+
+// SetReadTimeout 设置单条请求的读取超时
+// 从请求的第一个字节到达开始计时，必须在该时间内读完整条请求
+// 与空闲超时分开计算，逐字节慢速发送的客户端不能长期占用连接
+// d <= 0 时使用默认值 30 秒
+// 只对之后建立的连接生效
+func (s *Server) SetReadTimeout(d time.Duration) {
+	if d <= 0 {
+		d = defaultReadTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readTimeout = d
+}
+
+// connOptions 返回新连接使用的读写选项
+func (s *Server) connOptions() connOptions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return connOptions{
+		maxMessageSize:  s.maxRequestBytes,
+		idleTimeout:     s.idleTimeout,
+		readTimeout:     s.readTimeout,
+		readBufferSize:  s.readBufferSize,
+		writeBufferSize: s.writeBufferSize,
+	}
+}
+
 // Serve 启动 RPC 服务器，监听指定地址
-// network: 网络类型，如 "tcp", "tcp4", "tcp6"
+// network: 网络类型，如 "tcp", "tcp4", "tcp6", "unix"
 // address: 监听地址，如 ":8080", "localhost:8080"
 // 此方法会阻塞直到服务器关闭或发生错误
 func (s *Server) Serve(network, address string) error {
-	// 检查是否已经在运行
-	s.mu.Lock()
-	if s.listener != nil {
-		s.mu.Unlock()
-		return fmt.Errorf("server is already running")
+	if s.IsShutdown() {
+		return ErrServerClosed
 	}
 
 	// 创建监听器
 	listener, err := net.Listen(network, address)
 	if err != nil {
-		s.mu.Unlock()
 		return fmt.Errorf("failed to listen on %s:%s: %w", network, address, err)
 	}
 
-	s.listener = listener
+	return s.ServeListener(listener)
+}
+
+// ServeListener 在给定的监听器上接受连接并处理请求
+// 可以传入 TLS 监听器、systemd 传递的 socket、内存管道等任意 net.Listener
+// 可以在多个协程中分别调用，同时服务多个监听器（如 TCP 和 Unix socket）
+// 此方法会阻塞直到服务器关闭或监听器出错，返回时监听器已被关闭
+// 因服务器关闭而返回时返回 nil
+// 通过 SetTLSConfig 设置了 TLS 配置时，监听器会被 TLS 包装（不要再传入 TLS 监听器）
+func (s *Server) ServeListener(listener net.Listener) error {
+	s.mu.Lock()
+	if s.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
 	s.mu.Unlock()
 
+	if !s.trackListener(listener) {
+		listener.Close()
+		return ErrServerClosed
+	}
+	defer s.untrackListener(listener)
+
 	// 接受连接循环
 	for {
 		// 检查是否已关闭
 		if atomic.LoadInt32(&s.shutdown) == 1 {
-			break
+			return nil
 		}
 
 		// 接受新连接
@@ -93,43 +456,192 @@ func (s *Server) Serve(network, address string) error {
 		if err != nil {
 			// 检查是否是因为关闭导致的错误
 			if atomic.LoadInt32(&s.shutdown) == 1 {
-				break
+				return nil
+			}
+			// 监听器被外部关闭，无法继续接受连接
+			if errors.Is(err, net.ErrClosed) {
+				return err
 			}
 			// 记录错误但继续接受其他连接
-			// 在生产环境中应该使用日志库
-			fmt.Printf("accept error: %v\n", err)
+			s.logger.Error("accept error", "addr", listener.Addr().String(), "error", err)
+			if s.metrics != nil {
+				s.metrics.AcceptError()
+			}
+			continue
+		}
+
+		// 超过最大连接数时快速拒绝，不占用协程池队列
+		priority := PriorityNormal
+		if s.connPriority != nil {
+			priority = s.connPriority(conn)
+		}
+		if !s.acquireConn(priority) {
+			s.rejectConn(conn)
 			continue
 		}
 
 		// 使用协程池处理连接
 		// 性能优化：避免为每个连接创建新的 goroutine
 		s.wg.Add(1)
-		if err := s.pool.Submit(func() {
+		task := func() {
 			defer s.wg.Done()
 			s.handleConn(conn)
-		}); err != nil {
-			// 协程池已关闭或出错，关闭连接
+		}
+		shed := func() {
+			// 连接在队列中被新连接挤掉
+			s.wg.Done()
+			s.releaseConn()
+			s.rejectConn(conn)
+		}
+		if err := s.submitConn(priority, task, shed); err != nil {
 			s.wg.Done()
-			conn.Close()
+			s.releaseConn()
+			if errors.Is(err, ErrPoolFull) {
+				// 协程池已满，按过载策略快速拒绝
+				s.rejectConn(conn)
+			} else {
+				// 协程池已关闭或出错，关闭连接
+				conn.Close()
+			}
+		}
+	}
+}
+
+// trackListener 登记监听器，服务器已关闭时返回 false
+func (s *Server) trackListener(listener net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if atomic.LoadInt32(&s.shutdown) == 1 {
+		return false
+	}
+	s.listeners = append(s.listeners, listener)
+	return true
+}
+
+// trackConn 登记一个不经过监听器接受的连接（如 WebSocket），Shutdown 会等待它处理完成
+// 与 trackListener 相同，在持有锁时检查关闭标志：Shutdown 设置标志后才会取得锁并开始等待，
+// 因此不会在 wg.Wait 之后调用 wg.Add
+// 返回 false 表示服务器已关闭；返回 true 时调用者处理完成后必须调用 s.wg.Done
+func (s *Server) trackConn() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if atomic.LoadInt32(&s.shutdown) == 1 {
+		return false
+	}
+	s.wg.Add(1)
+	return true
+}
+
+// untrackListener 移除并关闭监听器
+func (s *Server) untrackListener(listener net.Listener) {
+	s.mu.Lock()
+	for i, l := range s.listeners {
+		if l == listener {
+			s.listeners = append(s.listeners[:i], s.listeners[i+1:]...)
+			break
 		}
 	}
+	s.mu.Unlock()
 
-	return nil
+	listener.Close()
 }
 
-// handleConn 处理单个客户端连接
+// closeListeners 关闭所有监听器，停止接受新连接
+// 返回第一个关闭错误
+func (s *Server) closeListeners() error {
+	s.mu.Lock()
+	listeners := s.listeners
+	s.listeners = nil
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, l := range listeners {
+		if err := l.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// handleConn 处理单个客户端连接，连接名额已由接受连接的循环占用
+// 以换行符分隔 JSON-RPC 消息，限制消息大小并设置读取超时，避免连接长时间占用
+func (s *Server) handleConn(conn net.Conn) {
+	// TLS 连接在这里完成握手，握手失败（如客户端证书无效）直接关闭连接
+	peer, err := connPeer(conn)
+	if err != nil {
+		s.logger.Warn("tls handshake error", "remote_addr", conn.RemoteAddr().String(), "error", err)
+		s.releaseConn()
+		conn.Close()
+		return
+	}
+
+	s.serveConn(newLineConn(conn, s.connOptions()), peer)
+}
+
+// serveConn 处理一个面向消息的连接（TCP 或 WebSocket）
 // 实现完整的请求处理流程：读取 -> 解码 -> 调用 -> 编码 -> 响应
 // 性能优化：
 // 1. 使用 bufio 减少系统调用
 // 2. 使用对象池复用 Request/Response 对象
 // 3. 支持在同一连接上处理多个请求（keep-alive）
-func (s *Server) handleConn(conn net.Conn) {
+// 4. 同一连接上的请求并发处理（并发数可配置），默认响应按完成顺序写回（客户端按 ID 匹配）
+// 5. 可以通过 SetStrictOrdering 改为按请求顺序写回
+// 调用者必须已经通过 acquireConn 占用连接名额，serveConn 返回时释放
+func (s *Server) serveConn(conn messageConn, peer *Peer) {
 	defer conn.Close()
 
-	// 使用 bufio 包装连接，减少系统调用
-	// 性能优化：批量读写，减少 I/O 次数
-	reader := bufio.NewReaderSize(conn, 32*1024) // 32KB 读缓冲
-	writer := bufio.NewWriterSize(conn, 32*1024) // 32KB 写缓冲
+	if s.hooks.OnConnect != nil {
+		if err := s.hooks.OnConnect(peer); err != nil {
+			s.releaseConn()
+			return
+		}
+	}
+	if s.hooks.OnDisconnect != nil {
+		// 在释放连接名额之后调用，回调中可以立即建立新连接
+		defer s.hooks.OnDisconnect(peer)
+	}
+	defer s.releaseConn()
+
+	if s.metrics != nil {
+		s.metrics.ConnOpened()
+		defer s.metrics.ConnClosed()
+	}
+
+	// 服务方法可以通过 context 获取连接（向客户端推送通知）和客户端身份
+	sc := &ServerConn{server: s, conn: conn}
+	ctx := context.WithValue(context.Background(), serverConnKey{}, sc)
+	ctx = contextWithPeer(ctx, peer)
+
+	// 连接出错（读取失败或写回响应失败）时取消所有正在执行的服务方法，避免被放弃的请求继续占用资源
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	limit, ordered := s.connConcurrency, s.strictOrdering
+	s.mu.Unlock()
+
+	var (
+		inflight sync.WaitGroup               // 等待连接上的请求处理完成
+		sem      = make(chan struct{}, limit) // 限制单连接并发请求数
+	)
+
+	// 关闭连接前等待所有请求写回响应
+	defer inflight.Wait()
+
+	// 严格顺序模式：请求并发执行，但响应由写协程按请求到达的顺序写回
+	// results 中按顺序存放每个请求的结果 channel，容量与并发上限一致，不会阻塞读循环
+	var results chan chan []byte
+	if ordered {
+		results = make(chan chan []byte, limit)
+		inflight.Add(1)
+		go func() {
+			defer inflight.Done()
+			s.writeOrdered(sc, results, sem, cancel)
+		}()
+		defer close(results)
+	}
 
 	// 处理连接上的多个请求
 	for {
@@ -138,89 +650,357 @@ func (s *Server) handleConn(conn net.Conn) {
 			break
 		}
 
-		// 设置读取超时，避免连接长时间占用
-		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
-
-		// 读取一行数据（JSON-RPC 消息以换行符分隔）
-		data, err := reader.ReadBytes('\n')
+		// 读取一条完整的消息
+		data, err := conn.ReadMessage()
 		if err != nil {
 			if err == io.EOF {
-				// 客户端正常关闭连接
+				// 客户端关闭了写方向（半关闭或正常关闭连接）：不再读取新请求，
+				// 已收到的请求继续执行并写回响应，写回失败时再取消
+				break
+			}
+			// 连接出错或 WebSocket 连接已关闭，响应无法送达
+			cancel()
+			if errors.Is(err, errWSClosed) {
+				break
+			}
+			if errors.Is(err, ErrMessageTooLarge) {
+				// 请求过大：剩余数据无法分帧，返回错误响应后关闭连接
+				// 此时无法得知请求 ID，按规范使用 null
+				limit := s.connOptions().maxMessageSize
+				sc.write(s.encodeErrorResponse(nil, NewRequestTooLargeError(limit)))
 				break
 			}
 			// 读取错误，关闭连接
-			fmt.Printf("read error: %v\n", err)
+			s.logger.Error("read error", "remote_addr", sc.RemoteAddr().String(), "error", err)
 			break
 		}
 
-		// 处理请求并生成响应
-		respData := s.processRequest(data)
+		if s.metrics != nil {
+			s.metrics.BytesReceived(len(data))
+		}
+
+		// 取消通知在读循环中直接处理，不受连接并发名额的限制
+		if s.cancelInline(ctx, data) {
+			continue
+		}
 
-		// 发送响应
-		if respData != nil {
-			// 设置写入超时
-			conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+		if ordered {
+			// 严格顺序模式下响应按到达顺序写回，不区分优先级
+			sem <- struct{}{}
+			inflight.Add(1)
+			result := make(chan []byte, 1)
+			results <- result
+			s.dispatch(func() {
+				defer inflight.Done()
+				result <- s.processRequest(ctx, data)
+			})
+			continue
+		}
 
-			// 写入响应数据
-			if _, err := writer.Write(respData); err != nil {
-				fmt.Printf("write error: %v\n", err)
-				break
+		// 并发处理请求，读循环继续读取下一条消息
+		// 高优先级请求（取消通知、健康检查等）不占用连接的并发名额，
+		// 连接上的普通请求达到并发上限时不会被阻塞在读循环中
+		high := s.messagePriority(data) == PriorityHigh
+		if !high {
+			sem <- struct{}{}
+		}
+		inflight.Add(1)
+
+		s.dispatch(func() {
+			defer func() {
+				if !high {
+					<-sem
+				}
+				inflight.Done()
+			}()
+
+			// 处理请求并生成响应
+			respData := s.processRequest(ctx, data)
+			if respData == nil {
+				return
 			}
 
-			// 刷新缓冲区，确保数据发送
-			if err := writer.Flush(); err != nil {
-				fmt.Printf("flush error: %v\n", err)
-				break
+			// 发送响应，响应按完成顺序写回
+			if err := sc.write(respData); err != nil {
+				s.logger.Error("write error", "remote_addr", sc.RemoteAddr().String(), "error", err)
+				cancel()
+				sc.conn.Close() // 中断读循环
+			}
+		})
+	}
+}
+
+// writeOrdered 严格顺序模式的写协程，按请求到达的顺序写回响应
+// 每写完（或跳过）一个响应释放一个并发名额；写入失败时调用 cancel 取消连接上的其他请求
+func (s *Server) writeOrdered(sc *ServerConn, results <-chan chan []byte, sem <-chan struct{}, cancel context.CancelFunc) {
+	failed := false
+	for result := range results {
+		respData := <-result
+		if respData != nil && !failed {
+			if err := sc.write(respData); err != nil {
+				s.logger.Error("write error", "remote_addr", sc.RemoteAddr().String(), "error", err)
+				cancel()
+				sc.conn.Close() // 中断读循环
+				// 继续消费剩余结果，释放并发名额
+				failed = true
 			}
 		}
+		<-sem
 	}
 }
 
-// processRequest 处理单个请求
-// 实现请求解码 -> 服务调用 -> 响应编码的完整流程
+// dispatch 执行连接上的单个请求
+// 优先交给协程池中空闲的 worker；没有空闲 worker 时启动新的 goroutine
+// 请求不能在协程池队列中排队：worker 可能全部被长连接占用，排队的请求会一直得不到执行
+// 并发数已经由每个连接的并发上限约束
+func (s *Server) dispatch(task func()) {
+	if !s.pool.tryHandoff(task) {
+		go task()
+	}
+}
+
+// processRequest 处理一条 JSON-RPC 消息
+// 消息可以是单个请求对象，也可以是批量请求数组
 // 返回编码后的响应数据
-func (s *Server) processRequest(data []byte) []byte {
+func (s *Server) processRequest(ctx context.Context, data []byte) []byte {
+	if isBatch(data) {
+		return s.processBatch(ctx, data)
+	}
+	return s.processSingle(ctx, data)
+}
+
+// processSingle 处理单个请求
+// 实现请求解码 -> 服务调用 -> 响应编码的完整流程
+// 返回编码后的响应数据，通知请求返回 nil
+func (s *Server) processSingle(ctx context.Context, data []byte) []byte {
 	// 解码请求
 	// 性能优化：使用对象池复用 Request 对象
 	req, err := s.codec.DecodeRequest(data)
 	if err != nil {
 		// 解码失败，返回错误响应
-		return s.encodeErrorResponse(nil, err.(*Error))
+		// 此时无法判断是否为通知，按规范始终返回错误
+		// 自定义编解码器返回的普通错误按 Parse error 处理
+		rpcErr, ok := AsError(err)
+		if !ok {
+			rpcErr = NewParseError(err.Error())
+		}
+		return s.encodeErrorResponse(nil, rpcErr)
+	}
+	// 只有 JSONCodec 从它自己的对象池中取出请求，其他编解码器解码的请求不归还
+	if codec, ok := s.codec.(*JSONCodec); ok {
+		defer codec.ReleaseRequest(req)
 	}
-	defer PutRequest(req)
 
-	// 解析服务名和方法名
-	// 格式：ServiceName.MethodName
-	serviceName, methodName, err := parseMethod(req.Method)
-	if err != nil {
-		return s.encodeErrorResponse(req.ID, NewMethodNotFoundError(req.Method))
+	// 通知不返回任何响应，包括错误响应
+	if req.IsNotification() {
+		s.callRequest(ctx, req)
+		return nil
+	}
+
+	ctx, tr := contextWithTrailer(ctx)
+	result, rpcErr := s.callRequest(ctx, req)
+	trailer := tr.metadata()
+
+	if rpcErr != nil {
+		return s.encodeErrorResponseWithTrailer(req.ID, rpcErr, trailer)
+	}
+
+	// 编码成功响应
+	return s.encodeSuccessResponse(req.ID, result, trailer)
+}
+
+// callRequest 调用请求对应的服务方法
+// 返回调用结果或 JSON-RPC 错误
+func (s *Server) callRequest(ctx context.Context, req *Request) (result interface{}, rpcErr *Error) {
+	// 取消通知由协议层处理，不经过拦截器
+	if req.Method == CancelRequestMethod {
+		s.handleCancel(ctx, req.Params)
+		return nil, nil
+	}
+
+	if s.metrics != nil || s.accessLog {
+		start := time.Now()
+		method := unknownMethod
+		if s.metrics != nil {
+			method = s.metricsMethod(req.Method)
+			s.metrics.ServerRequestStarted(method)
+		}
+		defer func() {
+			duration := time.Since(start)
+			if s.metrics != nil {
+				var err error
+				if rpcErr != nil {
+					err = rpcErr
+				}
+				s.metrics.ServerRequestFinished(method, err, duration)
+			}
+			if s.accessLog {
+				s.logRequest(ctx, req, duration, rpcErr)
+			}
+		}()
+	}
+
+	ctx = contextWithIncomingMetadata(ctx, req.Metadata)
+	if !req.IsNotification() {
+		ctx = contextWithRequestID(ctx, req.ID)
+	}
+
+	// 认证器、限流器、Tracer、拦截器和服务方法中的 panic 都在这里恢复，
+	// 经过 PanicHandler 上报并转换为内部错误，不会导致进程崩溃，客户端也能收到响应
+	defer func() {
+		if v := recover(); v != nil {
+			result, rpcErr = nil, s.registry.panicError(ctx, req.Method, v)
+		}
+	}()
+
+	// 提取调用方的链路信息，配置了 Tracer 时创建服务端 span
+	ctx = contextWithTraceParent(ctx, req.TraceParent, req.TraceState)
+	if s.tracer != nil {
+		var span Span
+		ctx, span = startSpan(ctx, s.tracer, req.Method, SpanKindServer)
+		defer func() {
+			if rpcErr != nil {
+				span.SetError(rpcErr)
+			}
+			span.End()
+		}()
+	}
+
+	// 超过最大并发请求数时快速拒绝，在认证之前检查以减少过载时的开销
+	// 高优先级请求不会被拒绝
+	defer s.releaseRequest()
+	if !s.admitRequest(s.requestPriority(req)) {
+		return nil, NewServerOverloadedError()
+	}
+
+	// 认证调用方，未通过时不进入拦截器链
+	if s.authenticator != nil {
+		if ctx, rpcErr = s.authenticate(ctx, req); rpcErr != nil {
+			return nil, rpcErr
+		}
 	}
 
-	// 创建上下文
-	// 可以在这里添加超时控制、取消信号等
-	ctx := context.Background()
+	// 限流，客户端标识可以使用认证得到的调用方身份
+	if s.rateLimiter != nil {
+		if wait, ok := s.rateLimiter.Allow(ctx, req.Method); !ok {
+			atomic.AddUint64(&s.rateLimited, 1)
+			return nil, NewRateLimitedError(wait)
+		}
+	}
 
-	// 调用服务方法
-	// 性能优化：使用缓存的反射信息，避免运行时反射开销
-	result, err := s.registry.Call(ctx, serviceName, methodName, req.Params)
+	// 使用客户端传递的剩余超时时间，客户端放弃等待后服务方法也随之取消
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.Timeout)*time.Millisecond)
+		defer cancel()
+	}
+
+	// 登记到连接的在途请求表，以便客户端通过 $/cancelRequest 取消
+	if sc, ok := ServerConnFromContext(ctx); ok && !req.IsNotification() {
+		var untrack func()
+		ctx, untrack = sc.trackRequest(ctx, req.ID)
+		defer untrack()
+		// 取消通知先于请求登记到达，不再调用服务方法
+		if ctx.Err() == context.Canceled {
+			return nil, NewRequestCancelledError()
+		}
+	}
+
+	// 经过拦截器链调用服务方法
+	handler := s.handler.Load().(Handler)
+	result, err := handler(ctx, req.Method, req.Params)
 	if err != nil {
+		// 服务方法因取消（$/cancelRequest 或连接断开）而结束
+		// 注册表已将错误转换为 *Error，这里根据 ctx 判断
+		if ctx.Err() == context.Canceled {
+			return nil, NewRequestCancelledError()
+		}
 		// 服务调用失败
-		if rpcErr, ok := err.(*Error); ok {
-			return s.encodeErrorResponse(req.ID, rpcErr)
+		return nil, toRPCError(err)
+	}
+
+	return result, nil
+}
+
+// processBatch 处理批量请求
+// 参考规范：https://www.jsonrpc.org/specification#batch
+// 1. 数组本身无法解析时返回单个 Parse error
+// 2. 空数组返回单个 Invalid Request 错误
+// 3. 数组中的每一项独立处理，错误项返回各自的错误响应
+// 4. 通知不产生响应，全部为通知时不返回任何内容
+// 性能优化：批量中的各项通过协程池并发执行
+func (s *Server) processBatch(ctx context.Context, data []byte) []byte {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return s.encodeErrorResponse(nil, NewParseError(err.Error()))
+	}
+
+	if len(items) == 0 {
+		return s.encodeErrorResponse(nil, NewInvalidRequestError("empty batch"))
+	}
+
+	// 并发处理各项，结果按原始顺序存放
+	results := make([][]byte, len(items))
+	s.pool.parallel(len(items), func(i int) {
+		// 数组元素必须是请求对象，例如 [1,2,3] 中的每一项都是无效请求
+		if firstByte(items[i]) != '{' {
+			results[i] = s.encodeErrorResponse(nil, NewInvalidRequestError("batch item is not an object"))
+			return
+		}
+		results[i] = s.processSingle(ctx, items[i])
+	})
+
+	// 拼接为响应数组，每个响应末尾的换行符需要去掉
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+
+	buf.WriteByte('[')
+	count := 0
+	for _, result := range results {
+		if result == nil {
+			continue
 		}
-		return s.encodeErrorResponse(req.ID, NewInternalError(err.Error()))
+		if count > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(bytes.TrimRight(result, "\n"))
+		count++
 	}
+	buf.WriteString("]\n")
 
-	// 编码成功响应
-	return s.encodeSuccessResponse(req.ID, result)
+	if count == 0 {
+		return nil
+	}
+
+	out := make([]byte, buf.Len())
+	copy(out, buf.Bytes())
+	return out
 }
 
-// encodeSuccessResponse 编码成功响应
-func (s *Server) encodeSuccessResponse(id interface{}, result interface{}) []byte {
+// isBatch 判断消息是否为批量请求（第一个非空白字符为 '['）
+func isBatch(data []byte) bool {
+	return firstByte(data) == '['
+}
+
+// firstByte 返回第一个非空白字符，数据全为空白时返回 0
+func firstByte(data []byte) byte {
+	for _, b := range data {
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		default:
+			return b
+		}
+	}
+	return 0
+}
+
+// encodeSuccessResponse 编码成功响应，trailer 为服务方法设置的响应元数据
+func (s *Server) encodeSuccessResponse(id interface{}, result interface{}, trailer Metadata) []byte {
 	// 序列化结果
 	resultData, err := json.Marshal(result)
 	if err != nil {
-		return s.encodeErrorResponse(id, NewInternalError(fmt.Sprintf("failed to marshal result: %v", err)))
+		return s.encodeErrorResponseWithTrailer(id, NewInternalError(fmt.Sprintf("failed to marshal result: %v", err)), trailer)
 	}
 
 	// 创建响应对象
@@ -231,6 +1011,7 @@ func (s *Server) encodeSuccessResponse(id interface{}, result interface{}) []byt
 	resp.Jsonrpc = JSONRPCVersion
 	resp.Result = resultData
 	resp.ID = id
+	resp.Trailer = trailer
 
 	// 编码响应
 	data, err := s.codec.EncodeResponse(resp)
@@ -243,6 +1024,11 @@ func (s *Server) encodeSuccessResponse(id interface{}, result interface{}) []byt
 
 // encodeErrorResponse 编码错误响应
 func (s *Server) encodeErrorResponse(id interface{}, rpcErr *Error) []byte {
+	return s.encodeErrorResponseWithTrailer(id, rpcErr, nil)
+}
+
+// encodeErrorResponseWithTrailer 编码带响应元数据的错误响应
+func (s *Server) encodeErrorResponseWithTrailer(id interface{}, rpcErr *Error, trailer Metadata) []byte {
 	// 创建响应对象
 	// 性能优化：使用对象池
 	resp := GetResponse()
@@ -251,6 +1037,7 @@ func (s *Server) encodeErrorResponse(id interface{}, rpcErr *Error) []byte {
 	resp.Jsonrpc = JSONRPCVersion
 	resp.Error = rpcErr
 	resp.ID = id
+	resp.Trailer = trailer
 
 	// 编码响应
 	data, err := s.codec.EncodeResponse(resp)
@@ -296,14 +1083,8 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		return fmt.Errorf("server is already shutdown")
 	}
 
-	// 关闭监听器，停止接受新连接
-	s.mu.Lock()
-	var listenerErr error
-	if s.listener != nil {
-		listenerErr = s.listener.Close()
-		s.listener = nil
-	}
-	s.mu.Unlock()
+	// 关闭所有监听器，停止接受新连接
+	listenerErr := s.closeListeners()
 
 	// 创建一个 channel 用于等待所有连接处理完成
 	done := make(chan struct{})
@@ -334,14 +1115,8 @@ func (s *Server) Close() error {
 	// 设置关闭标志
 	atomic.StoreInt32(&s.shutdown, 1)
 
-	// 关闭监听器
-	s.mu.Lock()
-	var err error
-	if s.listener != nil {
-		err = s.listener.Close()
-		s.listener = nil
-	}
-	s.mu.Unlock()
+	// 关闭所有监听器
+	err := s.closeListeners()
 
 	// 关闭协程池
 	s.pool.Close()
@@ -349,19 +1124,54 @@ func (s *Server) Close() error {
 	return err
 }
 
+// ServerStats 服务器统计信息
+type ServerStats struct {
+	ActiveConns       int64  // 当前连接数（TCP 和 WebSocket，不含 HTTP）
+	ActiveRequests    int64  // 正在执行的请求数
+	CancelledRequests uint64 // 被客户端 $/cancelRequest 取消的请求数
+	RateLimited       uint64 // 被限流拒绝的请求数
+	RejectedConns     uint64 // 因过载或超过最大连接数被拒绝的连接数
+	RejectedRequests  uint64 // 因超过最大并发请求数被拒绝的请求数
+}
+
+// Stats 获取服务器统计信息
+func (s *Server) Stats() ServerStats {
+	return ServerStats{
+		ActiveConns:       atomic.LoadInt64(&s.activeConns),
+		ActiveRequests:    atomic.LoadInt64(&s.activeRequests),
+		CancelledRequests: atomic.LoadUint64(&s.cancelled),
+		RateLimited:       atomic.LoadUint64(&s.rateLimited),
+		RejectedConns:     atomic.LoadUint64(&s.rejectedConns),
+		RejectedRequests:  atomic.LoadUint64(&s.rejectedRequests),
+	}
+}
+
 // IsShutdown 检查服务器是否已关闭
 func (s *Server) IsShutdown() bool {
 	return atomic.LoadInt32(&s.shutdown) == 1
 }
 
 // Addr 返回服务器监听的地址
+// 同时服务多个监听器时返回最先加入的一个
 // 如果服务器未启动，返回 nil
 func (s *Server) Addr() net.Addr {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.listener == nil {
+	if len(s.listeners) == 0 {
 		return nil
 	}
-	return s.listener.Addr()
+	return s.listeners[0].Addr()
+}
+
+// Addrs 返回所有正在服务的监听器地址
+func (s *Server) Addrs() []net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	addrs := make([]net.Addr, 0, len(s.listeners))
+	for _, l := range s.listeners {
+		addrs = append(addrs, l.Addr())
+	}
+	return addrs
 }
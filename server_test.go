@@ -0,0 +1,1148 @@
+package rerpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestServer 创建注册了 TestService 的服务器（不监听端口）
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	server := NewServer(4)
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// decodeBatch 将批量响应解码为 Response 列表
+func decodeBatch(t *testing.T, data []byte) []Response {
+	t.Helper()
+
+	var resps []Response
+	if err := json.Unmarshal(data, &resps); err != nil {
+		t.Fatalf("Failed to decode batch response %q: %v", data, err)
+	}
+	return resps
+}
+
+// TestServer_Batch 测试批量请求
+func TestServer_Batch(t *testing.T) {
+	server := newTestServer(t)
+
+	data := []byte(`[
+		{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2},"id":1},
+		{"jsonrpc":"2.0","method":"TestService.Missing","params":{},"id":"two"},
+		{"jsonrpc":"2.0","method":"TestService.Echo","params":{"message":"hi"},"id":3}
+	]` + "\n")
+
+	resps := decodeBatch(t, server.processRequest(context.Background(), data))
+	if len(resps) != 3 {
+		t.Fatalf("Expected 3 responses, got %d", len(resps))
+	}
+
+	// 响应顺序与请求一致，ID 逐项对应
+	if resps[0].ID != float64(1) || resps[0].Error != nil || string(resps[0].Result) != `{"result":3}` {
+		t.Errorf("Unexpected response 0: %+v (result %s)", resps[0], resps[0].Result)
+	}
+	if resps[1].ID != "two" || resps[1].Error == nil || resps[1].Error.Code != ErrCodeMethodNotFound {
+		t.Errorf("Unexpected response 1: %+v", resps[1])
+	}
+	if resps[2].ID != float64(3) || string(resps[2].Result) != `{"message":"hi"}` {
+		t.Errorf("Unexpected response 2: %+v (result %s)", resps[2], resps[2].Result)
+	}
+}
+
+// TestServer_BatchInvalid 测试无效的批量请求
+func TestServer_BatchInvalid(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name      string
+		data      string
+		wantCodes []int
+		wantArray bool
+	}{
+		{
+			name:      "空数组",
+			data:      `[]`,
+			wantCodes: []int{ErrCodeInvalidRequest},
+		},
+		{
+			name:      "无效的 JSON 数组",
+			data:      `[{"jsonrpc":"2.0","method":"TestService.Add"`,
+			wantCodes: []int{ErrCodeParse},
+		},
+		{
+			name:      "非对象元素",
+			data:      `[1,2,3]`,
+			wantCodes: []int{ErrCodeInvalidRequest, ErrCodeInvalidRequest, ErrCodeInvalidRequest},
+			wantArray: true,
+		},
+		{
+			name:      "字段类型错误",
+			data:      `[{"jsonrpc":"2.0","method":1,"id":1}]`,
+			wantCodes: []int{ErrCodeInvalidRequest},
+			wantArray: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := server.processRequest(context.Background(), []byte(tt.data+"\n"))
+
+			var resps []Response
+			if tt.wantArray {
+				resps = decodeBatch(t, out)
+			} else {
+				var resp Response
+				if err := json.Unmarshal(out, &resp); err != nil {
+					t.Fatalf("Expected single response, got %q: %v", out, err)
+				}
+				resps = []Response{resp}
+			}
+
+			if len(resps) != len(tt.wantCodes) {
+				t.Fatalf("Expected %d responses, got %d", len(tt.wantCodes), len(resps))
+			}
+			for i, resp := range resps {
+				if resp.Error == nil || resp.Error.Code != tt.wantCodes[i] {
+					t.Errorf("Response %d: expected code %d, got %+v", i, tt.wantCodes[i], resp.Error)
+				}
+				if resp.ID != nil {
+					t.Errorf("Response %d: expected null id, got %v", i, resp.ID)
+				}
+			}
+		})
+	}
+}
+
+// customCodec 不使用对象池的自定义编解码器，解码失败时返回普通错误
+type customCodec struct {
+	*JSONCodec
+	last *Request // 最近一次解码的请求
+}
+
+func (c *customCodec) DecodeRequest(data []byte) (*Request, error) {
+	req := new(Request)
+	if err := json.Unmarshal(data, req); err != nil {
+		return nil, errors.New("bad request")
+	}
+	c.last = req
+	return req, nil
+}
+
+// TestServer_CustomCodec 测试自定义编解码器的普通错误和请求对象的归属
+func TestServer_CustomCodec(t *testing.T) {
+	codec := &customCodec{JSONCodec: NewJSONCodec(nil)}
+	server := NewServerWithConfig(ServerConfig{Codec: codec})
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	out := server.processRequest(context.Background(), []byte(`{"jsonrpc":`))
+	if !strings.Contains(string(out), `"code":-32700`) || !strings.Contains(string(out), "bad request") {
+		t.Errorf("Expected parse error, got %s", out)
+	}
+
+	out = server.processRequest(context.Background(), []byte(`{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2},"id":1}`))
+	if !strings.Contains(string(out), `"result":3`) {
+		t.Errorf("Unexpected response: %s", out)
+	}
+	// 自定义编解码器解码的请求不会被归还到全局对象池（归还时会被重置）
+	if codec.last == nil || codec.last.Method != "TestService.Add" {
+		t.Errorf("Expected request to be left untouched, got %+v", codec.last)
+	}
+}
+
+// TestServer_Notification 测试通知请求不返回响应
+func TestServer_Notification(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name     string
+		data     string
+		wantResp bool
+	}{
+		{
+			name: "通知",
+			data: `{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2}}`,
+		},
+		{
+			name: "方法不存在的通知",
+			data: `{"jsonrpc":"2.0","method":"TestService.Missing"}`,
+		},
+		{
+			name:     "id 为 null 的请求",
+			data:     `{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2},"id":null}`,
+			wantResp: true,
+		},
+		{
+			name: "全部为通知的批量请求",
+			data: `[{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2}},{"jsonrpc":"2.0","method":"TestService.Missing"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := server.processRequest(context.Background(), []byte(tt.data+"\n"))
+			if tt.wantResp && out == nil {
+				t.Fatal("Expected a response, got none")
+			}
+			if !tt.wantResp && out != nil {
+				t.Fatalf("Expected no response, got %q", out)
+			}
+		})
+	}
+
+	// 混合批量请求只返回非通知项的响应
+	out := server.processRequest(context.Background(), []byte(`[
+		{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2}},
+		{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":3,"b":4},"id":7}
+	]`))
+	resps := decodeBatch(t, out)
+	if len(resps) != 1 || resps[0].ID != float64(7) {
+		t.Errorf("Expected only response for id 7, got %s", out)
+	}
+}
+
+// pipeListener 基于 net.Pipe 的内存监听器，用于不占用端口的测试
+type pipeListener struct {
+	conns     chan net.Conn
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// Accept 返回下一个通过 Dial 建立的连接
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Dial 建立一个连接，返回客户端一端
+func (l *pipeListener) Dial() (net.Conn, error) {
+	client, server := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+// roundTrip 在原始连接上发送一行请求并读取一行响应
+func roundTrip(t *testing.T, conn net.Conn, request string) string {
+	t.Helper()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte(request + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return line
+}
+
+// TestServer_ServeListener 测试在自定义监听器上提供服务
+func TestServer_ServeListener(t *testing.T) {
+	server := newTestServer(t)
+	listener := newPipeListener()
+
+	done := make(chan error, 1)
+	go func() { done <- server.ServeListener(listener) }()
+
+	conn, err := listener.Dial()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+
+	resp := roundTrip(t, conn, `{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2},"id":1}`)
+	if !strings.Contains(resp, `"result":{"result":3}`) {
+		t.Errorf("Unexpected response: %s", resp)
+	}
+
+	if addr := server.Addr(); addr == nil || addr.Network() != "pipe" {
+		t.Errorf("Expected pipe address, got %v", addr)
+	}
+
+	// Close 会等待连接处理协程退出，先关闭客户端连接
+	conn.Close()
+	server.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected nil after close, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeListener did not return after Close")
+	}
+
+	// 关闭后不再接受新的监听器
+	if err := server.ServeListener(newPipeListener()); err != ErrServerClosed {
+		t.Errorf("Expected ErrServerClosed, got %v", err)
+	}
+}
+
+// TestServer_MultipleListeners 测试同时监听 TCP 和 Unix socket
+func TestServer_MultipleListeners(t *testing.T) {
+	server := NewServer(4)
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+
+	tcpListener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen tcp failed: %v", err)
+	}
+	unixListener, err := net.Listen("unix", filepath.Join(t.TempDir(), "rerpc.sock"))
+	if err != nil {
+		tcpListener.Close()
+		t.Skipf("unix socket not supported: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, l := range []net.Listener{tcpListener, unixListener} {
+		wg.Add(1)
+		go func(l net.Listener) {
+			defer wg.Done()
+			if err := server.ServeListener(l); err != nil {
+				t.Errorf("ServeListener failed: %v", err)
+			}
+		}(l)
+	}
+
+	// 等待两个监听器都开始服务
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.Addrs()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 2 listeners, got %v", server.Addrs())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, addr := range []net.Addr{tcpListener.Addr(), unixListener.Addr()} {
+		conn, err := net.Dial(addr.Network(), addr.String())
+		if err != nil {
+			t.Fatalf("Dial %s failed: %v", addr, err)
+		}
+		resp := roundTrip(t, conn, `{"jsonrpc":"2.0","method":"TestService.Echo","params":{"message":"hi"},"id":1}`)
+		conn.Close()
+		if !strings.Contains(resp, `"message":"hi"`) {
+			t.Errorf("%s: unexpected response: %s", addr.Network(), resp)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	wg.Wait()
+
+	if addrs := server.Addrs(); len(addrs) != 0 {
+		t.Errorf("Expected no listeners after shutdown, got %v", addrs)
+	}
+}
+
+// BlockingService 阻塞直到 context 结束，用于测试取消传播
+type BlockingService struct {
+	started chan struct{} // 服务方法开始执行
+	done    chan error    // 服务方法结束时的 ctx.Err()
+}
+
+func newBlockingService() *BlockingService {
+	return &BlockingService{
+		started: make(chan struct{}, 16),
+		done:    make(chan error, 16),
+	}
+}
+
+func (s *BlockingService) Wait(ctx context.Context, args *EchoArgs, reply *EchoReply) error {
+	s.started <- struct{}{}
+	<-ctx.Done()
+	s.done <- ctx.Err()
+	return ctx.Err()
+}
+
+// waitDone 等待服务方法结束并返回其 ctx.Err()
+func (s *BlockingService) waitDone(t *testing.T) error {
+	t.Helper()
+
+	select {
+	case err := <-s.done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Handler was not cancelled")
+		return nil
+	}
+}
+
+// TestServer_RequestTimeout 测试请求中的 timeout 成员限制服务方法的执行时间
+func TestServer_RequestTimeout(t *testing.T) {
+	server := NewServer(4)
+	service := newBlockingService()
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	start := time.Now()
+	out := server.processRequest(context.Background(), []byte(`{"jsonrpc":"2.0","method":"BlockingService.Wait","params":{},"timeout":50,"id":1}`))
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Request took %v, expected about 50ms", elapsed)
+	}
+	if err := service.waitDone(t); err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	if !strings.Contains(string(out), `"error"`) {
+		t.Errorf("Expected error response, got %s", out)
+	}
+}
+
+// TestServer_CancelOnDisconnect 测试连接异常断开时取消正在执行的服务方法
+func TestServer_CancelOnDisconnect(t *testing.T) {
+	server := NewServerWithConfig(ServerConfig{
+		Workers: 4,
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	service := newBlockingService()
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	// 关闭时发送 RST，服务端读取出错
+	conn.(*net.TCPConn).SetLinger(0)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte(`{"jsonrpc":"2.0","method":"BlockingService.Wait","params":{},"id":1}` + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	select {
+	case <-service.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Handler did not start")
+	}
+
+	conn.Close()
+	if err := service.waitDone(t); err != context.Canceled {
+		t.Errorf("Expected Canceled, got %v", err)
+	}
+}
+
+// TestServer_HalfClose 测试客户端关闭写方向后，已发送的请求继续执行并写回响应
+func TestServer_HalfClose(t *testing.T) {
+	server := NewServer(4)
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request := fmt.Sprintf(`{"jsonrpc":"2.0","method":"TestService.Sleep","params":{"duration":%d},"id":1}`, 200*time.Millisecond)
+	if _, err := conn.Write([]byte(request + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := conn.(*net.TCPConn).CloseWrite(); err != nil {
+		t.Fatalf("CloseWrite failed: %v", err)
+	}
+
+	resp, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !strings.Contains(string(resp), `"result":{"message":"done"}`) {
+		t.Errorf("Expected result, got %s", resp)
+	}
+}
+
+// DeadlineService 返回服务方法 ctx 的剩余时间
+type DeadlineService struct{}
+
+func (s *DeadlineService) Remaining(ctx context.Context, args *EchoArgs, reply *EchoReply) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return errors.New("no deadline")
+	}
+	reply.Message = time.Until(deadline).String()
+	return nil
+}
+
+// TestClient_DeadlinePropagation 测试客户端将剩余超时时间传递给服务端
+// 直接检查服务方法 ctx 的截止时间，不依赖服务端计时器与客户端放弃请求的先后顺序
+func TestClient_DeadlinePropagation(t *testing.T) {
+	server := NewServer(4)
+	if err := server.Register(&DeadlineService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	for _, multiplex := range []bool{false, true} {
+		client, err := NewClient(ClientConfig{Address: listener.Addr().String(), Multiplex: multiplex})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		var reply EchoReply
+		err = client.Call(ctx, "DeadlineService.Remaining", &EchoArgs{}, &reply)
+		cancel()
+		client.Close()
+		if err != nil {
+			t.Fatalf("Call failed (multiplex=%v): %v", multiplex, err)
+		}
+
+		// 服务方法的截止时间来自客户端剩余的超时时间，而不是服务端的默认值
+		remaining, err := time.ParseDuration(reply.Message)
+		if err != nil {
+			t.Fatalf("Unexpected reply (multiplex=%v): %q", multiplex, reply.Message)
+		}
+		if remaining <= 0 || remaining > 2*time.Second {
+			t.Errorf("Expected remaining time within client timeout (multiplex=%v), got %v", multiplex, remaining)
+		}
+	}
+}
+
+// TestServer_CancelRequest 测试 $/cancelRequest 取消同一连接上的请求
+// 连接的并发名额被占满（包括严格顺序模式）时取消通知仍然能被处理
+func TestServer_CancelRequest(t *testing.T) {
+	configs := map[string]ServerConfig{
+		"default":         {Workers: 4},
+		"strict-ordering": {Workers: 4, ConnConcurrency: 1, StrictOrdering: true},
+	}
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			testCancelRequest(t, NewServerWithConfig(config))
+		})
+	}
+}
+
+func testCancelRequest(t *testing.T, server *Server) {
+	service := newBlockingService()
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener := newPipeListener()
+	go server.ServeListener(listener)
+
+	conn, err := listener.Dial()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte(`{"jsonrpc":"2.0","method":"BlockingService.Wait","params":{},"id":"req-1"}` + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	<-service.started
+
+	// 未知 ID 的取消被忽略
+	if _, err := conn.Write([]byte(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":"other"}}` + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := conn.Write([]byte(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":"req-1"}}` + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !strings.Contains(line, `"code":-32800`) || !strings.Contains(line, `"id":"req-1"`) {
+		t.Errorf("Expected request cancelled error, got %s", line)
+	}
+	if err := service.waitDone(t); err != context.Canceled {
+		t.Errorf("Expected Canceled, got %v", err)
+	}
+	if stats := server.Stats(); stats.CancelledRequests != 1 || stats.ActiveConns != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+// TestServer_CancelBackToBack 测试紧跟在请求之后的取消通知在请求登记之前到达时不会丢失
+func TestServer_CancelBackToBack(t *testing.T) {
+	server := NewServer(4)
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener := newPipeListener()
+	go server.ServeListener(listener)
+
+	conn, err := listener.Dial()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	for i := 1; i <= 20; i++ {
+		request := fmt.Sprintf(`{"jsonrpc":"2.0","method":"TestService.Sleep","params":{"duration":%d},"id":%d}`, 5*time.Second, i)
+		cancel := fmt.Sprintf(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":%d}}`, i)
+		if _, err := conn.Write([]byte(request + "\n" + cancel + "\n")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if !strings.Contains(line, `"code":-32800`) || !strings.Contains(line, fmt.Sprintf(`"id":%d`, i)) {
+			t.Fatalf("Expected request cancelled error, got %s", line)
+		}
+	}
+	if stats := server.Stats(); stats.CancelledRequests != 20 {
+		t.Errorf("Expected 20 cancelled requests, got %+v", stats)
+	}
+}
+
+// TestClient_CancelRequest 测试客户端在 context 取消时自动发送 $/cancelRequest
+func TestClient_CancelRequest(t *testing.T) {
+	server := NewServer(4)
+	service := newBlockingService()
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	for _, multiplex := range []bool{false, true} {
+		client, err := NewClient(ClientConfig{Address: listener.Addr().String(), Multiplex: multiplex})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		cancelledBefore := server.Stats().CancelledRequests
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-service.started
+			cancel()
+		}()
+
+		if err := client.Call(ctx, "BlockingService.Wait", &EchoArgs{}, &EchoReply{}); err != context.Canceled {
+			t.Errorf("Expected Canceled (multiplex=%v), got %v", multiplex, err)
+		}
+		if err := service.waitDone(t); err != context.Canceled {
+			t.Errorf("Expected handler Canceled (multiplex=%v), got %v", multiplex, err)
+		}
+		if stats := client.Stats(); stats.CancelledCalls != 1 {
+			t.Errorf("Expected 1 cancelled call (multiplex=%v), got %d", multiplex, stats.CancelledCalls)
+		}
+
+		// 多路复用连接不会断开，取消只能通过 $/cancelRequest 完成
+		// 非多路复用模式下连接随即被丢弃，断开连接可能先于取消通知生效
+		if multiplex {
+			if stats := server.Stats(); stats.CancelledRequests != cancelledBefore+1 {
+				t.Errorf("Expected server to count the cancellation, got %+v", stats)
+			}
+		}
+
+		// 被取消的调用不影响后续调用
+		reqCtx, reqCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		client.Call(reqCtx, "BlockingService.Wait", &EchoArgs{}, &EchoReply{})
+		reqCancel()
+		<-service.started
+		service.waitDone(t)
+
+		client.Close()
+	}
+}
+
+// pipelineResponses 在一个连接上管道化发送请求，返回响应 ID 的顺序和总耗时
+func pipelineResponses(t *testing.T, server *Server, requests []string) ([]string, time.Duration) {
+	t.Helper()
+
+	listener := newPipeListener()
+	go server.ServeListener(listener)
+
+	conn, err := listener.Dial()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	start := time.Now()
+	go func() {
+		for _, req := range requests {
+			conn.Write([]byte(req + "\n"))
+		}
+	}()
+
+	reader := bufio.NewReader(conn)
+	ids := make([]string, 0, len(requests))
+	for range requests {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		var resp struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.Unmarshal(line, &resp); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		ids = append(ids, string(resp.ID))
+	}
+	return ids, time.Since(start)
+}
+
+// TestServer_Pipelining 测试同一连接上管道化请求的并发执行和响应顺序
+func TestServer_Pipelining(t *testing.T) {
+	const sleep = 200 * time.Millisecond
+	requests := []string{
+		`{"jsonrpc":"2.0","method":"TestService.Sleep","params":{"duration":200000000},"id":1}`,
+		`{"jsonrpc":"2.0","method":"TestService.Sleep","params":{"duration":200000000},"id":2}`,
+		`{"jsonrpc":"2.0","method":"TestService.Echo","params":{"message":"fast"},"id":3}`,
+	}
+
+	t.Run("按完成顺序", func(t *testing.T) {
+		server := newTestServer(t)
+		ids, elapsed := pipelineResponses(t, server, requests)
+		if ids[0] != "3" {
+			t.Errorf("Expected fast request to complete first, got %v", ids)
+		}
+		if elapsed >= 2*sleep {
+			t.Errorf("Expected concurrent execution, took %v", elapsed)
+		}
+	})
+
+	t.Run("严格顺序", func(t *testing.T) {
+		server := newTestServer(t)
+		server.SetStrictOrdering(true)
+		ids, elapsed := pipelineResponses(t, server, requests)
+		if !reflect.DeepEqual(ids, []string{"1", "2", "3"}) {
+			t.Errorf("Expected responses in request order, got %v", ids)
+		}
+		if elapsed >= 2*sleep {
+			t.Errorf("Expected concurrent execution, took %v", elapsed)
+		}
+	})
+
+	t.Run("逐个执行", func(t *testing.T) {
+		server := newTestServer(t)
+		server.SetConnConcurrency(1)
+		ids, elapsed := pipelineResponses(t, server, requests)
+		if !reflect.DeepEqual(ids, []string{"1", "2", "3"}) {
+			t.Errorf("Expected responses in request order, got %v", ids)
+		}
+		if elapsed < 2*sleep {
+			t.Errorf("Expected serial execution, took %v", elapsed)
+		}
+	})
+}
+
+// TestServer_MaxRequestBytes 测试过大的请求返回错误响应并关闭连接
+func TestServer_MaxRequestBytes(t *testing.T) {
+	server := newTestServer(t)
+	server.SetMaxRequestBytes(128)
+
+	listener := newPipeListener()
+	go server.ServeListener(listener)
+
+	conn, err := listener.Dial()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	resp := roundTrip(t, conn, `{"jsonrpc":"2.0","method":"TestService.Echo","params":{"message":"small"},"id":1}`)
+	if !strings.Contains(resp, `"message":"small"`) {
+		t.Fatalf("Unexpected response: %s", resp)
+	}
+
+	large := `{"jsonrpc":"2.0","method":"TestService.Echo","params":{"message":"` + strings.Repeat("x", 256) + `"},"id":2}`
+	go conn.Write([]byte(large + "\n"))
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	var errResp Response
+	if err := json.Unmarshal(line, &errResp); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if errResp.Error == nil || errResp.Error.Code != ErrCodeInvalidRequest || errResp.Error.Message != ErrMsgRequestTooLarge {
+		t.Fatalf("Expected request too large error, got %s", line)
+	}
+	if errResp.ID != nil {
+		t.Errorf("Expected null id, got %v", errResp.ID)
+	}
+
+	// 连接随后被关闭
+	if _, err := reader.ReadBytes('\n'); err == nil {
+		t.Error("Expected connection to be closed")
+	}
+}
+
+// TestServer_ReadTimeout 测试慢速发送和不发送数据的连接会被断开，空闲连接不受影响
+func TestServer_ReadTimeout(t *testing.T) {
+	const (
+		readTimeout = 100 * time.Millisecond
+		idleTimeout = 10 * readTimeout
+	)
+
+	server := newTestServer(t)
+	server.SetReadTimeout(readTimeout)
+	server.SetIdleTimeout(idleTimeout)
+
+	listener := newPipeListener()
+	go server.ServeListener(listener)
+
+	// waitClosed 等待服务端关闭连接，返回耗时
+	waitClosed := func(conn net.Conn) time.Duration {
+		start := time.Now()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := bufio.NewReader(conn).ReadBytes('\n'); err == nil {
+			t.Error("Expected connection to be closed")
+		}
+		return time.Since(start)
+	}
+
+	t.Run("慢速发送", func(t *testing.T) {
+		conn, err := listener.Dial()
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+
+		// 只发送请求的一部分，不发送换行符
+		go conn.Write([]byte(`{"jsonrpc":"2.0","method":`))
+		if elapsed := waitClosed(conn); elapsed >= time.Second {
+			t.Errorf("Expected connection to be closed after read timeout, took %v", elapsed)
+		}
+	})
+
+	t.Run("不发送数据", func(t *testing.T) {
+		conn, err := listener.Dial()
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+
+		// 新连接等待第一条消息同样只受空闲超时限制
+		if elapsed := waitClosed(conn); elapsed < idleTimeout/2 || elapsed >= 5*time.Second {
+			t.Errorf("Expected connection to be closed after idle timeout, took %v", elapsed)
+		}
+	})
+
+	t.Run("预先建立的连接", func(t *testing.T) {
+		conn, err := listener.Dial()
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+
+		// 客户端连接池提前建立的连接，超过读取超时后才发送第一条请求
+		time.Sleep(3 * readTimeout)
+		request := `{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2},"id":1}`
+		if resp := roundTrip(t, conn, request); !strings.Contains(resp, `"result":3`) {
+			t.Errorf("Unexpected response: %s", resp)
+		}
+	})
+
+	t.Run("空闲连接", func(t *testing.T) {
+		conn, err := listener.Dial()
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+
+		request := `{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2},"id":1}`
+		roundTrip(t, conn, request)
+
+		// 两次请求之间的空闲时间只受空闲超时限制
+		time.Sleep(3 * readTimeout)
+		if resp := roundTrip(t, conn, request); !strings.Contains(resp, `"result":3`) {
+			t.Errorf("Unexpected response: %s", resp)
+		}
+	})
+}
+
+// TestServer_MaxRequestBytesBoundary 测试恰好达到大小限制的请求（不含换行符）被正常处理
+func TestServer_MaxRequestBytesBoundary(t *testing.T) {
+	const limit = 128
+
+	server := NewServerWithConfig(ServerConfig{
+		Workers:         4,
+		MaxRequestBytes: limit,
+		ReadBufferSize:  16, // 请求跨多个缓冲区读取
+	})
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener := newPipeListener()
+	go server.ServeListener(listener)
+
+	conn, err := listener.Dial()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	// 按目标长度填充 message
+	request := func(size int) string {
+		prefix := `{"jsonrpc":"2.0","method":"TestService.Echo","params":{"message":"`
+		suffix := `"},"id":1}`
+		return prefix + strings.Repeat("x", size-len(prefix)-len(suffix)) + suffix
+	}
+
+	if resp := roundTrip(t, conn, request(limit)); !strings.Contains(resp, `"result"`) {
+		t.Fatalf("Expected request at the limit to succeed, got %s", resp)
+	}
+
+	// 超过限制后服务端不再读取剩余数据
+	go conn.Write([]byte(request(limit+1) + "\n"))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !strings.Contains(line, ErrMsgRequestTooLarge) {
+		t.Errorf("Expected request too large error, got %s", line)
+	}
+}
+
+// TestHTTPHandler_MaxRequestBytes 测试 HTTP 传输拒绝过大的请求体
+func TestHTTPHandler_MaxRequestBytes(t *testing.T) {
+	server := newTestServer(t)
+	server.SetMaxRequestBytes(128)
+
+	ts := httptest.NewServer(server.HTTPHandler())
+	defer ts.Close()
+
+	large := `{"jsonrpc":"2.0","method":"TestService.Echo","params":{"message":"` + strings.Repeat("x", 256) + `"},"id":1}`
+	resp, err := http.Post(ts.URL, "application/json", strings.NewReader(large))
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), ErrMsgRequestTooLarge) {
+		t.Errorf("Expected request too large error, got %s", body)
+	}
+}
+
+// TestClient_MaxResponseBytes 测试客户端拒绝过大的响应
+func TestClient_MaxResponseBytes(t *testing.T) {
+	server := newTestServer(t)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, multiplex := range []bool{false, true} {
+		client, err := NewClient(ClientConfig{
+			Address:          listener.Addr().String(),
+			Multiplex:        multiplex,
+			MaxRetries:       0,
+			MaxResponseBytes: 128,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+
+		err = client.Call(ctx, "TestService.Echo", &EchoArgs{Message: strings.Repeat("x", 256)}, &EchoReply{})
+		if !errors.Is(err, ErrMessageTooLarge) {
+			t.Errorf("Expected ErrMessageTooLarge (multiplex=%v), got %v", multiplex, err)
+		}
+
+		// 出错的连接被丢弃，之后的调用使用新连接
+		reply := &EchoReply{}
+		if err := client.Call(ctx, "TestService.Echo", &EchoArgs{Message: "small"}, reply); err != nil {
+			t.Errorf("Call after oversized response (multiplex=%v) failed: %v", multiplex, err)
+		}
+		client.Close()
+	}
+}
+
+// TestNewServerWithConfig 测试服务器配置的默认值和最大连接数、连接回调、日志
+func TestNewServerWithConfig(t *testing.T) {
+	defaults := NewServer(0)
+	defer defaults.Close()
+	if defaults.idleTimeout != defaultIdleTimeout || defaults.writeTimeout != defaultWriteTimeout ||
+		defaults.readBufferSize != defaultBufferSize || defaults.maxRequestBytes != defaultMaxMessageSize ||
+		defaults.connConcurrency != defaultConnConcurrency || defaults.logger == nil {
+		t.Errorf("Unexpected defaults: %+v", defaults)
+	}
+
+	var (
+		mu           sync.Mutex
+		connected    int
+		disconnected = make(chan struct{}, 4)
+	)
+	server := NewServerWithConfig(ServerConfig{
+		Workers:         4,
+		MaxConns:        1,
+		WriteTimeout:    time.Second,
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		Logger:          slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		Hooks: ServerHooks{
+			OnConnect: func(peer *Peer) error {
+				mu.Lock()
+				defer mu.Unlock()
+				connected++
+				if peer.Addr == nil {
+					return errors.New("no peer address")
+				}
+				return nil
+			},
+			OnDisconnect: func(peer *Peer) {
+				disconnected <- struct{}{}
+			},
+		},
+	})
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener := newPipeListener()
+	go server.ServeListener(listener)
+
+	first, err := listener.Dial()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	request := `{"jsonrpc":"2.0","method":"TestService.Add","params":{"a":1,"b":2},"id":1}`
+	if resp := roundTrip(t, first, request); !strings.Contains(resp, `"result":3`) {
+		t.Fatalf("Unexpected response: %s", resp)
+	}
+
+	// 超过最大连接数的连接收到过载错误后被关闭
+	second, err := listener.Dial()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	second.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(second)
+	if line, err := reader.ReadString('\n'); err != nil || !strings.Contains(line, `"code":-32003`) {
+		t.Errorf("Expected overloaded error, got %q, %v", line, err)
+	}
+	if _, err := reader.ReadByte(); err == nil {
+		t.Error("Expected connection over MaxConns to be closed")
+	}
+	second.Close()
+	if stats := server.Stats(); stats.RejectedConns != 1 {
+		t.Errorf("Expected 1 rejected connection, got %d", stats.RejectedConns)
+	}
+
+	first.Close()
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnDisconnect was not called")
+	}
+
+	mu.Lock()
+	if connected != 1 {
+		t.Errorf("Expected OnConnect to be called once, got %d", connected)
+	}
+	mu.Unlock()
+
+	// 连接释放后可以建立新连接
+	third, err := listener.Dial()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer third.Close()
+	if resp := roundTrip(t, third, request); !strings.Contains(resp, `"result":3`) {
+		t.Fatalf("Unexpected response: %s", resp)
+	}
+}
@@ -0,0 +1,114 @@
+package rerpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+)
+
+// tlsHandshakeTimeout 服务端等待 TLS 握手完成的超时时间
+const tlsHandshakeTimeout = 10 * time.Second
+
+// SetTLSConfig 设置服务端 TLS 配置
+// 设置后 Serve 和 ServeListener 会用 TLS 包装监听器，必须在启动服务之前调用
+// 要求客户端证书（mTLS）时设置 ClientAuth 为 tls.RequireAndVerifyClientCert 并配置 ClientCAs
+// HTTPHandler 和 WebSocketHandler 的 TLS 由外部的 http.Server 负责，不受此配置影响
+func (s *Server) SetTLSConfig(config *tls.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tlsConfig = config
+}
+
+// Peer 发起当前请求的客户端信息
+// 服务方法可以通过 PeerFromContext 获取，用于基于调用方身份的授权
+type Peer struct {
+	Addr net.Addr             // 客户端地址
+	TLS  *tls.ConnectionState // TLS 连接状态，非 TLS 连接为 nil
+}
+
+// peerKey context 中保存 *Peer 的键
+type peerKey struct{}
+
+// PeerFromContext 获取发起当前请求的客户端信息
+// 所有传输（TCP、HTTP、WebSocket）都会设置
+func PeerFromContext(ctx context.Context) (*Peer, bool) {
+	peer, ok := ctx.Value(peerKey{}).(*Peer)
+	return peer, ok
+}
+
+// contextWithPeer 将客户端信息保存到 context
+func contextWithPeer(ctx context.Context, peer *Peer) context.Context {
+	return context.WithValue(ctx, peerKey{}, peer)
+}
+
+// Certificate 返回经过验证的客户端证书
+// 只有证书链验证通过时才返回（ClientAuth 为 VerifyClientCertIfGiven 或 RequireAndVerifyClientCert），
+// 未验证的证书不能用于授权，此时返回 nil
+func (p *Peer) Certificate() *x509.Certificate {
+	if p.TLS == nil || len(p.TLS.VerifiedChains) == 0 || len(p.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return p.TLS.VerifiedChains[0][0]
+}
+
+// CommonName 返回已验证客户端证书的 Subject CN，没有已验证证书时返回空字符串
+func (p *Peer) CommonName() string {
+	if cert := p.Certificate(); cert != nil {
+		return cert.Subject.CommonName
+	}
+	return ""
+}
+
+// SANs 返回已验证客户端证书的所有 Subject Alternative Names
+// 依次包含 DNS 名称、邮箱、IP 地址和 URI（如 SPIFFE ID）
+func (p *Peer) SANs() []string {
+	cert := p.Certificate()
+	if cert == nil {
+		return nil
+	}
+
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.EmailAddresses)+len(cert.IPAddresses)+len(cert.URIs))
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
+// connPeer 获取连接的客户端信息
+// TLS 连接会先完成握手，以便读取客户端证书
+func connPeer(conn net.Conn) (*Peer, error) {
+	peer := &Peer{Addr: conn.RemoteAddr()}
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return peer, nil
+	}
+
+	tlsConn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	state := tlsConn.ConnectionState()
+	peer.TLS = &state
+	return peer, nil
+}
+
+// requestPeer 获取 HTTP 请求的客户端信息
+func requestPeer(r *http.Request) *Peer {
+	peer := &Peer{TLS: r.TLS}
+	if addrPort, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		peer.Addr = net.TCPAddrFromAddrPort(addrPort)
+	}
+	return peer
+}
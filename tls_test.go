@@ -0,0 +1,255 @@
+package rerpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPKI 测试用的 CA、服务端证书和客户端证书
+type testPKI struct {
+	pool   *x509.CertPool
+	server tls.Certificate
+	client tls.Certificate
+}
+
+// newTestPKI 生成自签名 CA，并签发服务端证书（localhost）和客户端证书（worker-a）
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "rerpc test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+
+	issue := func(serial int64, template *x509.Certificate) tls.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey failed: %v", err)
+		}
+		template.SerialNumber = big.NewInt(serial)
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("CreateCertificate failed: %v", err)
+		}
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+
+	spiffeID, _ := url.Parse("spiffe://example.org/worker-a")
+
+	pki := &testPKI{pool: x509.NewCertPool()}
+	pki.pool.AddCert(caCert)
+	pki.server = issue(2, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	pki.client = issue(3, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "worker-a"},
+		URIs:        []*url.URL{spiffeID},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return pki
+}
+
+// serverConfig 要求并验证客户端证书的服务端 TLS 配置
+func (p *testPKI) serverConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{p.server},
+		ClientCAs:    p.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+}
+
+// clientConfig 客户端 TLS 配置，withCert 决定是否提供客户端证书
+func (p *testPKI) clientConfig(withCert bool) *tls.Config {
+	config := &tls.Config{RootCAs: p.pool}
+	if withCert {
+		config.Certificates = []tls.Certificate{p.client}
+	}
+	return config
+}
+
+// PeerService 返回调用方身份，用于测试 PeerFromContext
+type PeerService struct{}
+
+type PeerReply struct {
+	CommonName string   `json:"common_name"`
+	SANs       []string `json:"sans"`
+}
+
+func (s *PeerService) WhoAmI(ctx context.Context, args *EchoArgs, reply *PeerReply) error {
+	peer, ok := PeerFromContext(ctx)
+	if !ok {
+		return errors.New("no peer in context")
+	}
+	if peer.Addr == nil {
+		return errors.New("no peer address")
+	}
+	reply.CommonName = peer.CommonName()
+	reply.SANs = peer.SANs()
+	return nil
+}
+
+// checkPeerReply 验证服务端看到的客户端身份
+func checkPeerReply(t *testing.T, reply *PeerReply) {
+	t.Helper()
+
+	if reply.CommonName != "worker-a" {
+		t.Errorf("Expected common name worker-a, got %q", reply.CommonName)
+	}
+	if len(reply.SANs) != 1 || reply.SANs[0] != "spiffe://example.org/worker-a" {
+		t.Errorf("Unexpected SANs: %v", reply.SANs)
+	}
+}
+
+// TestTLS_MutualTCP 测试 TCP 传输上的 mTLS
+func TestTLS_MutualTCP(t *testing.T) {
+	pki := newTestPKI(t)
+
+	server := NewServer(4)
+	if err := server.Register(&PeerService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	server.SetTLSConfig(pki.serverConfig())
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, multiplex := range []bool{false, true} {
+		client, err := NewClient(ClientConfig{
+			Address:   listener.Addr().String(),
+			TLSConfig: pki.clientConfig(true),
+			Multiplex: multiplex,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+
+		reply := &PeerReply{}
+		if err := client.Call(ctx, "PeerService.WhoAmI", &EchoArgs{}, reply); err != nil {
+			t.Fatalf("Call (multiplex=%v) failed: %v", multiplex, err)
+		}
+		checkPeerReply(t, reply)
+		client.Close()
+	}
+
+	// 没有客户端证书时握手失败
+	client, err := NewClient(ClientConfig{
+		Address:    listener.Addr().String(),
+		TLSConfig:  pki.clientConfig(false),
+		MaxRetries: 0,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Call(ctx, "PeerService.WhoAmI", &EchoArgs{}, &PeerReply{}); err == nil {
+		t.Error("Expected call without client certificate to fail")
+	}
+}
+
+// TestTLS_MutualHTTP 测试 HTTPS 和 WSS 传输上的 mTLS
+func TestTLS_MutualHTTP(t *testing.T) {
+	pki := newTestPKI(t)
+
+	server := NewServer(4)
+	if err := server.Register(&PeerService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/rpc", server.HTTPHandler())
+	mux.Handle("/ws", server.WebSocketHandler())
+
+	ts := httptest.NewUnstartedServer(mux)
+	ts.TLS = pki.serverConfig()
+	ts.StartTLS()
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addresses := []string{
+		ts.URL + "/rpc",
+		"wss" + strings.TrimPrefix(ts.URL, "https") + "/ws",
+	}
+	for _, address := range addresses {
+		client, err := NewClient(ClientConfig{
+			Address:   address,
+			TLSConfig: pki.clientConfig(true),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+
+		reply := &PeerReply{}
+		if err := client.Call(ctx, "PeerService.WhoAmI", &EchoArgs{}, reply); err != nil {
+			t.Fatalf("Call %s failed: %v", address, err)
+		}
+		checkPeerReply(t, reply)
+		client.Close()
+	}
+}
+
+// TestPeer_Unverified 测试未验证的证书不会作为调用方身份
+func TestPeer_Unverified(t *testing.T) {
+	pki := newTestPKI(t)
+	leaf, err := x509.ParseCertificate(pki.client.Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+
+	peer := &Peer{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}}
+	if peer.Certificate() != nil || peer.CommonName() != "" || peer.SANs() != nil {
+		t.Error("Expected unverified certificate to be ignored")
+	}
+
+	peer.TLS.VerifiedChains = [][]*x509.Certificate{{leaf}}
+	if peer.CommonName() != "worker-a" {
+		t.Errorf("Expected common name worker-a, got %q", peer.CommonName())
+	}
+}
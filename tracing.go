@@ -0,0 +1,214 @@
+package rerpc
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+)
+
+// W3C Trace Context 相关常量
+// 参考规范：https://www.w3.org/TR/trace-context/
+const (
+	traceParentHeader  = "traceparent"
+	traceStateHeader   = "tracestate"
+	traceParentVersion = "00"
+	traceParentLen     = 55 // 版本 00 的 traceparent 长度：2+1+32+1+16+1+2
+)
+
+// ErrInvalidTraceParent traceparent 格式不符合 W3C Trace Context 规范
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// TraceFlagsSampled traceparent 中的采样标志位
+const TraceFlagsSampled byte = 0x01
+
+// SpanContext 跨进程传递的链路信息，对应 W3C traceparent 和 tracestate
+type SpanContext struct {
+	TraceID    [16]byte // 链路 ID
+	SpanID     [8]byte  // 当前 span 的 ID，传给服务端后作为服务端 span 的父 span
+	Flags      byte     // 标志位，目前只定义了 TraceFlagsSampled
+	TraceState string   // tracestate 原样传递，不解析
+	Remote     bool     // 是否从对端的请求中解析得到
+}
+
+// IsValid 判断 TraceID 和 SpanID 是否都不为全零
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// IsSampled 判断是否设置了采样标志位
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&TraceFlagsSampled != 0
+}
+
+// TraceParent 返回版本 00 的 traceparent 字符串
+func (sc SpanContext) TraceParent() string {
+	buf := make([]byte, 0, traceParentLen)
+	buf = append(buf, traceParentVersion...)
+	buf = append(buf, '-')
+	buf = hex.AppendEncode(buf, sc.TraceID[:])
+	buf = append(buf, '-')
+	buf = hex.AppendEncode(buf, sc.SpanID[:])
+	buf = append(buf, '-')
+	buf = hex.AppendEncode(buf, []byte{sc.Flags})
+	return string(buf)
+}
+
+// ParseTraceParent 解析 traceparent 和 tracestate，返回的 SpanContext 标记为 Remote
+// 按规范处理版本：ff 无效；00 必须正好 55 个字符；更高的版本只解析前 55 个字符，之后必须以 '-' 分隔
+func ParseTraceParent(traceParent, traceState string) (SpanContext, error) {
+	if len(traceParent) < traceParentLen {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	version := traceParent[:2]
+	if !isLowerHex(version) || version == "ff" {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	if version == traceParentVersion && len(traceParent) != traceParentLen {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	if len(traceParent) > traceParentLen && traceParent[traceParentLen] != '-' {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	if traceParent[2] != '-' || traceParent[35] != '-' || traceParent[52] != '-' {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+
+	traceID, spanID, flags := traceParent[3:35], traceParent[36:52], traceParent[53:55]
+	if !isLowerHex(traceID) || !isLowerHex(spanID) || !isLowerHex(flags) {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+
+	sc := SpanContext{TraceState: traceState, Remote: true}
+	hex.Decode(sc.TraceID[:], []byte(traceID))
+	hex.Decode(sc.SpanID[:], []byte(spanID))
+	var f [1]byte
+	hex.Decode(f[:], []byte(flags))
+	sc.Flags = f[0]
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	return sc, nil
+}
+
+// isLowerHex 判断字符串是否只包含小写十六进制字符（规范不允许大写）
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// spanContextKey context 中保存 SpanContext 的键
+type spanContextKey struct{}
+
+// ContextWithSpanContext 将链路信息保存到 context
+// 客户端发起调用时会把 ctx 中的 SpanContext 写入请求的 traceparent 和 tracestate
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext 获取 context 中的链路信息
+// 服务方法中返回调用方传来的链路信息，配置了 Tracer 时返回服务端 span 的链路信息
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// SpanKind span 的类型
+type SpanKind int
+
+const (
+	SpanKindServer SpanKind = iota + 1 // 服务端处理一个请求
+	SpanKindClient                     // 客户端发起一次调用
+)
+
+// String 返回 span 类型的名称
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "unspecified"
+	}
+}
+
+// Tracer 链路追踪接口，用于接入 OpenTelemetry 等追踪系统
+// 服务端（ServerConfig.Tracer）为每个请求、客户端（ClientConfig.Tracer）为每次调用创建 span，实现必须是并发安全的
+type Tracer interface {
+	// Start 创建一个 span，name 为方法名
+	// 父 span 从 ctx 中获取：服务端为 SpanContextFromContext 返回的调用方链路信息（Remote 为 true），
+	// 客户端为调用方 ctx 中的链路信息或追踪系统自己保存在 ctx 中的 span
+	// 返回的 ctx 会被继续传递给服务方法或后续的拦截器
+	Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span)
+}
+
+// Span 一个正在进行的 span
+type Span interface {
+	// SpanContext 返回 span 的链路信息，客户端将其写入请求传给服务端
+	SpanContext() SpanContext
+
+	// SetError 记录调用失败，成功时不调用
+	SetError(err error)
+
+	// End 结束 span
+	End()
+}
+
+// startSpan 使用 Tracer 创建 span，并把 span 的链路信息保存到返回的 ctx 中
+func startSpan(ctx context.Context, tracer Tracer, name string, kind SpanKind) (context.Context, Span) {
+	ctx, span := tracer.Start(ctx, name, kind)
+	if sc := span.SpanContext(); sc.IsValid() {
+		ctx = ContextWithSpanContext(ctx, sc)
+	}
+	return ctx, span
+}
+
+// contextWithTraceParent 将请求中的 traceparent 和 tracestate 解析到 ctx
+// 格式无效时按规范忽略，ctx 保持不变
+func contextWithTraceParent(ctx context.Context, traceParent, traceState string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	sc, err := ParseTraceParent(traceParent, traceState)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, sc)
+}
+
+// contextWithTraceHeaders 从 HTTP 请求头中解析链路信息，请求消息中的 traceparent 成员优先
+func contextWithTraceHeaders(ctx context.Context, header http.Header) context.Context {
+	return contextWithTraceParent(ctx, header.Get(traceParentHeader), header.Get(traceStateHeader))
+}
+
+// injectTraceContext 将 ctx 中的链路信息写入请求
+// 没有配置 Tracer 时也会传递，服务方法中发起的调用因此能延续调用方的链路
+func injectTraceContext(ctx context.Context, req *Request) {
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		req.TraceParent = sc.TraceParent()
+		req.TraceState = sc.TraceState
+	}
+}
+
+// tracingMiddleware 为每次客户端调用创建 span 的拦截器，位于 Metrics 拦截器之内、用户拦截器之外
+func tracingMiddleware(tracer Tracer) ClientMiddleware {
+	return func(next Invoker) Invoker {
+		return func(ctx context.Context, method string, args, reply interface{}) error {
+			ctx, span := startSpan(ctx, tracer, method, SpanKindClient)
+			defer span.End()
+
+			err := next(ctx, method, args, reply)
+			if err != nil {
+				span.SetError(err)
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package rerpc
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSpan 测试用的 span
+type testSpan struct {
+	name   string
+	kind   SpanKind
+	parent SpanContext
+	sc     SpanContext
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) SpanContext() SpanContext { return s.sc }
+func (s *testSpan) SetError(err error)       { s.err = err }
+func (s *testSpan) End()                     { s.ended = true }
+
+// testTracer 记录所有创建的 span
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span) {
+	span := &testSpan{name: name, kind: kind}
+	span.parent, _ = SpanContextFromContext(ctx)
+	span.sc.TraceID = span.parent.TraceID
+	span.sc.Flags = span.parent.Flags
+	span.sc.TraceState = span.parent.TraceState
+	if !span.parent.IsValid() {
+		rand.Read(span.sc.TraceID[:])
+		span.sc.Flags = TraceFlagsSampled
+	}
+	rand.Read(span.sc.SpanID[:])
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return ctx, span
+}
+
+func (t *testTracer) find(kind SpanKind) *testSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, span := range t.spans {
+		if span.kind == kind {
+			return span
+		}
+	}
+	return nil
+}
+
+// TraceService 记录服务方法 ctx 中的链路信息
+type TraceService struct {
+	mu   sync.Mutex
+	seen []SpanContext
+}
+
+func (s *TraceService) Capture(ctx context.Context, args *EchoArgs, reply *EchoReply) error {
+	sc, _ := SpanContextFromContext(ctx)
+	s.mu.Lock()
+	s.seen = append(s.seen, sc)
+	s.mu.Unlock()
+	if args.Message == "fail" {
+		return errors.New("capture failed")
+	}
+	reply.Message = args.Message
+	return nil
+}
+
+func (s *TraceService) last() SpanContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.seen) == 0 {
+		return SpanContext{}
+	}
+	return s.seen[len(s.seen)-1]
+}
+
+// TestParseTraceParent 测试 traceparent 的解析和格式化
+func TestParseTraceParent(t *testing.T) {
+	const valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := ParseTraceParent(valid, "congo=t61rcWkgMzE")
+	if err != nil {
+		t.Fatalf("ParseTraceParent failed: %v", err)
+	}
+	if !sc.IsValid() || !sc.IsSampled() || !sc.Remote || sc.TraceState != "congo=t61rcWkgMzE" {
+		t.Errorf("Unexpected span context: %+v", sc)
+	}
+	if got := sc.TraceParent(); got != valid {
+		t.Errorf("Expected %s, got %s", valid, got)
+	}
+
+	// 更高的版本只解析前 55 个字符
+	if _, err := ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", ""); err != nil {
+		t.Errorf("Expected future version to be accepted, got %v", err)
+	}
+
+	invalid := []string{
+		"",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",  // 无效版本
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-", // 版本 00 长度不对
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",  // 大写
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",  // 全零 trace ID
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",  // 全零 span ID
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",  // 分隔符错误
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x", // 更高版本缺少分隔符
+	}
+	for _, tp := range invalid {
+		if _, err := ParseTraceParent(tp, ""); !errors.Is(err, ErrInvalidTraceParent) {
+			t.Errorf("Expected ErrInvalidTraceParent for %q, got %v", tp, err)
+		}
+	}
+}
+
+// TestTracing 测试客户端 span、traceparent 传递和服务端子 span
+func TestTracing(t *testing.T) {
+	tracer := &testTracer{}
+	service := &TraceService{}
+
+	server := NewServerWithConfig(ServerConfig{Workers: 4, Tracer: tracer})
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go server.ServeListener(listener)
+
+	client, err := NewClient(ClientConfig{Address: listener.Addr().String(), Tracer: tracer})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	parent, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "vendor=1")
+	ctx, cancel := context.WithTimeout(ContextWithSpanContext(context.Background(), parent), 5*time.Second)
+	defer cancel()
+
+	if err := client.Call(ctx, "TraceService.Capture", &EchoArgs{Message: "fail"}, &EchoReply{}); err == nil {
+		t.Fatal("Expected error")
+	}
+
+	clientSpan, serverSpan := tracer.find(SpanKindClient), tracer.find(SpanKindServer)
+	if clientSpan == nil || serverSpan == nil {
+		t.Fatalf("Expected client and server spans, got %d spans", len(tracer.spans))
+	}
+
+	// 客户端 span 的父 span 为调用方 ctx 中的链路信息
+	if clientSpan.name != "TraceService.Capture" || clientSpan.parent.SpanID != parent.SpanID {
+		t.Errorf("Unexpected client span: %+v", clientSpan)
+	}
+
+	// 服务端 span 的父 span 为客户端 span，并保留 tracestate
+	if serverSpan.parent.TraceID != parent.TraceID || serverSpan.parent.SpanID != clientSpan.sc.SpanID {
+		t.Errorf("Expected server span parent %x, got %x", clientSpan.sc.SpanID, serverSpan.parent.SpanID)
+	}
+	if !serverSpan.parent.Remote || serverSpan.parent.TraceState != "vendor=1" {
+		t.Errorf("Unexpected server span parent: %+v", serverSpan.parent)
+	}
+
+	// 服务方法 ctx 中是服务端 span 的链路信息
+	if got := service.last(); got.SpanID != serverSpan.sc.SpanID {
+		t.Errorf("Expected handler span %x, got %x", serverSpan.sc.SpanID, got.SpanID)
+	}
+
+	// 两端的 span 都记录了错误并结束
+	var rpcErr *Error
+	if !errors.As(serverSpan.err, &rpcErr) || clientSpan.err == nil {
+		t.Errorf("Expected errors on spans, got client=%v server=%v", clientSpan.err, serverSpan.err)
+	}
+	if !clientSpan.ended || !serverSpan.ended {
+		t.Error("Expected spans to be ended")
+	}
+}
+
+// TestTracing_Propagation 测试未配置 Tracer 时链路信息的传递
+func TestTracing_Propagation(t *testing.T) {
+	server := NewServer(4)
+	service := &TraceService{}
+	if err := server.Register(service); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+	defer server.Close()
+
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	parent, _ := ParseTraceParent(traceParent, "")
+
+	t.Run("TCP", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatalf("Listen failed: %v", err)
+		}
+		go server.ServeListener(listener)
+
+		client, err := NewClient(ClientConfig{Address: listener.Addr().String(), Multiplex: true})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		defer client.Close()
+
+		ctx, cancel := context.WithTimeout(ContextWithSpanContext(context.Background(), parent), 5*time.Second)
+		defer cancel()
+		if err := client.Call(ctx, "TraceService.Capture", &EchoArgs{Message: "hi"}, &EchoReply{}); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if got := service.last(); got.TraceParent() != traceParent || !got.Remote {
+			t.Errorf("Expected %s, got %+v", traceParent, got)
+		}
+
+		// ctx 中没有链路信息时不发送 traceparent
+		if err := client.Call(context.Background(), "TraceService.Capture", &EchoArgs{Message: "hi"}, &EchoReply{}); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if got := service.last(); got.IsValid() {
+			t.Errorf("Expected no span context, got %+v", got)
+		}
+	})
+
+	t.Run("HTTPHeader", func(t *testing.T) {
+		ts := httptest.NewServer(server.HTTPHandler())
+		defer ts.Close()
+
+		body := `{"jsonrpc":"2.0","method":"TraceService.Capture","params":{"message":"hi"},"id":1}`
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentTypeJSON)
+		req.Header.Set("traceparent", traceParent)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		resp.Body.Close()
+
+		if got := service.last(); got.TraceParent() != traceParent {
+			t.Errorf("Expected %s, got %+v", traceParent, got)
+		}
+	})
+}
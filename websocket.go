@@ -0,0 +1,498 @@
+package rerpc
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WebSocket 协议常量（RFC 6455）
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11" // 计算 Sec-WebSocket-Accept 使用的 GUID
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseNormal   = 1000 // 正常关闭
+	wsCloseProtocol = 1002 // 协议错误
+	wsCloseTooBig   = 1009 // 消息过大
+)
+
+// errWSProtocol 表示对端违反了 WebSocket 协议
+var errWSProtocol = errors.New("websocket: protocol error")
+
+// errWSClosed 表示对端发送了 close 帧
+// 与 TCP 半关闭不同，关闭握手完成后不能再发送数据帧，服务端据此取消连接上正在执行的请求
+// 包装 io.EOF，客户端仍按连接被关闭处理
+var errWSClosed = fmt.Errorf("websocket: closed by peer: %w", io.EOF)
+
+// WebSocketHandler 返回处理 JSON-RPC over WebSocket 的 http.Handler
+// 每条 WebSocket 文本消息承载一个 JSON-RPC 请求（或批量请求），响应同样以文本消息返回
+// 连接建立后与 TCP 传输共享同一套处理流程，服务方法可以通过 ServerConnFromContext 向客户端推送通知
+//
+// 握手失败时的状态码：
+//   - 400: 缺少 Upgrade/Connection/Sec-WebSocket-Key 头
+//   - 403: Origin 未通过 ServerConfig.CheckOrigin 校验（默认只允许同源）
+//   - 405: 非 GET 请求
+//   - 426: 不支持的 Sec-WebSocket-Version（仅支持 13）
+//   - 503: 服务器已关闭
+func (s *Server) WebSocketHandler() http.Handler {
+	return &wsHandler{server: s}
+}
+
+// wsHandler JSON-RPC over WebSocket 处理器
+type wsHandler struct {
+	server *Server
+}
+
+// ServeHTTP 完成 WebSocket 握手，然后在劫持的连接上处理请求
+func (h *wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := h.server
+	// 登记后 Shutdown 会等待该连接处理完成
+	if !s.trackConn() {
+		http.Error(w, "server is shutdown", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.wg.Done()
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+
+	// 浏览器会为跨站页面发起的 WebSocket 握手带上 Cookie，必须校验来源
+	if !s.checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// 超过最大连接数时在握手之前拒绝
+	if !s.acquireConn(PriorityNormal) {
+		atomic.AddUint64(&s.rejectedConns, 1)
+		http.Error(w, ErrMsgServerOverloaded, http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		s.releaseConn()
+		http.Error(w, "failed to hijack connection", http.StatusInternalServerError)
+		return
+	}
+
+	// 发送握手响应
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	brw.WriteString("Upgrade: websocket\r\n")
+	brw.WriteString("Connection: Upgrade\r\n")
+	brw.WriteString("Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		s.releaseConn()
+		conn.Close()
+		return
+	}
+
+	// 劫持后的读缓冲中可能已经有客户端发送的数据，必须继续使用它
+	s.serveConn(newWSConn(conn, brw.Reader, false, s.connOptions()), requestPeer(r))
+}
+
+// sameOrigin 默认的 Origin 校验：没有 Origin 头或 Origin 的主机与请求的 Host 相同（忽略大小写）
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// headerContainsToken 判断逗号分隔的请求头中是否包含指定的 token（忽略大小写）
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsAcceptKey 计算 Sec-WebSocket-Accept
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// isWebSocketAddress 判断客户端地址是否为 WebSocket URL
+func isWebSocketAddress(address string) bool {
+	return strings.HasPrefix(address, "ws://") || strings.HasPrefix(address, "wss://")
+}
+
+// dialWebSocket 建立 WebSocket 连接并完成握手
+// timeout 同时作为 TCP 连接、TLS 握手和 WebSocket 握手的总超时时间
+// tlsConfig 仅用于 wss://，为 nil 时使用默认配置
+func dialWebSocket(rawURL string, timeout time.Duration, tlsConfig *tls.Config, opts connOptions) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid websocket url: %w", err)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "wss" {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "wss" {
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		} else {
+			tlsConfig = tlsConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	// 握手阶段设置整体超时，完成后清除
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	path := u.RequestURI()
+	handshake := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + u.Host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := io.WriteString(conn, handshake); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send websocket handshake: %w", err)
+	}
+
+	reader := bufio.NewReaderSize(conn, 32*1024)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read websocket handshake: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake failed: %s", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		conn.Close()
+		return nil, fmt.Errorf("%w: invalid Sec-WebSocket-Accept", errWSProtocol)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return newWSConn(conn, reader, true, opts), nil
+}
+
+// wsConn 以 WebSocket 文本消息分隔 JSON-RPC 消息的连接
+// 实现 messageConn 接口：
+// 1. 读取时合并分片帧，自动回复 ping，收到 close 帧时返回 errWSClosed
+// 2. 每次写入发送一个完整的文本帧，客户端方向按协议要求加掩码
+type wsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	client bool        // 是否为客户端（客户端发送的帧必须加掩码）
+	limits connOptions // 读写选项
+
+	wmu       sync.Mutex    // 串行化写入
+	writer    *bufio.Writer // 写缓冲
+	closeSent int32         // 是否已发送 close 帧（原子操作）
+	closeCode uint32        // Close 时发送的关闭码，0 表示正常关闭（原子操作）
+}
+
+// newWSConn 包装已完成握手的连接
+// 读缓冲由握手阶段提供（服务端为 http.Server 劫持后的缓冲），只使用 opts 中的写缓冲大小
+func newWSConn(conn net.Conn, reader *bufio.Reader, client bool, opts connOptions) *wsConn {
+	return &wsConn{
+		conn:   conn,
+		reader: reader,
+		client: client,
+		limits: opts,
+		writer: bufio.NewWriterSize(conn, bufferSize(opts.writeBufferSize)),
+	}
+}
+
+// ReadMessage 读取一条完整的数据消息
+// 消息过大时不立即发送 close 帧：服务端先返回 JSON-RPC 错误响应，Close 时再以 1009 关闭
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var (
+		message []byte
+		started bool // 是否已收到消息的第一个分片
+	)
+	for {
+		// 控制帧不算消息的开始，收到 ping 后重新按空闲超时等待
+		if !started {
+			if err := c.limits.waitMessage(c.conn, c.reader); err != nil {
+				return nil, err
+			}
+		}
+
+		fin, opcode, payload, err := c.readFrame(len(message))
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrMessageTooLarge):
+				atomic.StoreUint32(&c.closeCode, wsCloseTooBig)
+			case errors.Is(err, errWSProtocol):
+				c.writeClose(wsCloseProtocol)
+			}
+			return nil, err
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload, time.Now().Add(30*time.Second)); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			// 对端发起关闭，回复 close 帧后视为连接正常结束
+			c.writeClose(wsCloseNormal)
+			return nil, errWSClosed
+		case wsOpText, wsOpBinary:
+			if started {
+				c.writeClose(wsCloseProtocol)
+				return nil, fmt.Errorf("%w: unexpected data frame", errWSProtocol)
+			}
+			started = true
+			message = payload
+		case wsOpContinuation:
+			if !started {
+				c.writeClose(wsCloseProtocol)
+				return nil, fmt.Errorf("%w: unexpected continuation frame", errWSProtocol)
+			}
+			message = append(message, payload...)
+		default:
+			c.writeClose(wsCloseProtocol)
+			return nil, fmt.Errorf("%w: unknown opcode %d", errWSProtocol, opcode)
+		}
+
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// readFrame 读取一个帧并去除掩码
+// buffered 是当前消息已经读取的字节数，用于限制分片消息的总大小
+func (c *wsConn) readFrame(buffered int) (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+
+	if header[0]&0x70 != 0 {
+		err = fmt.Errorf("%w: reserved bits set", errWSProtocol)
+		return
+	}
+	// 客户端发送的帧必须加掩码，服务端发送的帧不能加掩码
+	if masked == c.client {
+		err = fmt.Errorf("%w: invalid frame masking", errWSProtocol)
+		return
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	// 控制帧不能分片，负载不超过 125 字节
+	if opcode >= wsOpClose && (!fin || length > 125) {
+		err = fmt.Errorf("%w: invalid control frame", errWSProtocol)
+		return
+	}
+	if max := c.limits.maxMessageSize; max > 0 && length > uint64(max-buffered) {
+		err = ErrMessageTooLarge
+		return
+	}
+
+	var maskKey [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.reader, maskKey[:]); err != nil {
+			return
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= maskKey[i%4]
+		}
+	}
+	return
+}
+
+// WriteMessage 以单个文本帧写入一条消息
+// 行分隔传输使用的结尾换行符在 WebSocket 中是多余的，发送前去掉
+func (c *wsConn) WriteMessage(data []byte, deadline time.Time) error {
+	if n := len(data); n > 0 && data[n-1] == '\n' {
+		data = data[:n-1]
+	}
+	return c.writeFrame(wsOpText, data, deadline)
+}
+
+// writeFrame 写入一个完整的帧并刷新缓冲区
+func (c *wsConn) writeFrame(opcode byte, payload []byte, deadline time.Time) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if !deadline.IsZero() {
+		c.conn.SetWriteDeadline(deadline)
+		defer c.conn.SetWriteDeadline(time.Time{})
+	}
+
+	var header [14]byte
+	header[0] = 0x80 | opcode // FIN
+	n := 2
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+		n += 2
+	default:
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+		n += 8
+	}
+
+	if c.client {
+		header[1] |= 0x80
+		if _, err := rand.Read(header[n : n+4]); err != nil {
+			return err
+		}
+		maskKey := header[n : n+4]
+		n += 4
+
+		// 不修改调用方的数据
+		masked := make([]byte, length)
+		for i := range payload {
+			masked[i] = payload[i] ^ maskKey[i%4]
+		}
+		payload = masked
+	}
+
+	if _, err := c.writer.Write(header[:n]); err != nil {
+		return err
+	}
+	if _, err := c.writer.Write(payload); err != nil {
+		return err
+	}
+	return c.writer.Flush()
+}
+
+// writeClose 发送 close 帧（只发送一次）
+func (c *wsConn) writeClose(code uint16) {
+	if !atomic.CompareAndSwapInt32(&c.closeSent, 0, 1) {
+		return
+	}
+	var payload [2]byte
+	binary.BigEndian.PutUint16(payload[:], code)
+	c.writeFrame(wsOpClose, payload[:], time.Now().Add(time.Second))
+}
+
+// RemoteAddr 返回对端地址
+func (c *wsConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close 发送 close 帧后关闭底层连接
+func (c *wsConn) Close() error {
+	code := uint16(atomic.LoadUint32(&c.closeCode))
+	if code == 0 {
+		code = wsCloseNormal
+	}
+	c.writeClose(code)
+	return c.conn.Close()
+}
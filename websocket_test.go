@@ -0,0 +1,328 @@
+package rerpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newWebSocketTestServer 创建挂载 WebSocketHandler 的测试服务器，返回 ws:// 地址
+func newWebSocketTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+
+	server := NewServer(4)
+	if err := server.Register(&TestService{}); err != nil {
+		t.Fatalf("Failed to register service: %v", err)
+	}
+
+	ts := httptest.NewServer(server.WebSocketHandler())
+	t.Cleanup(func() {
+		ts.Close()
+		server.Close()
+	})
+
+	return server, "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+// TestWebSocket_Handshake 测试握手失败时的状态码
+func TestWebSocket_Handshake(t *testing.T) {
+	server := newTestServer(t)
+	ts := httptest.NewServer(server.WebSocketHandler())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		header     map[string]string
+		wantStatus int
+	}{
+		{
+			name:       "非 GET 请求",
+			method:     http.MethodPost,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "缺少 Upgrade 头",
+			method:     http.MethodGet,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "不支持的版本",
+			method: http.MethodGet,
+			header: map[string]string{
+				"Connection":            "keep-alive, Upgrade",
+				"Upgrade":               "websocket",
+				"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+				"Sec-WebSocket-Version": "8",
+			},
+			wantStatus: http.StatusUpgradeRequired,
+		},
+		{
+			name:   "跨站 Origin",
+			method: http.MethodGet,
+			header: map[string]string{
+				"Connection":            "Upgrade",
+				"Upgrade":               "websocket",
+				"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+				"Sec-WebSocket-Version": "13",
+				"Origin":                "https://evil.example",
+			},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL, nil)
+			if err != nil {
+				t.Fatalf("NewRequest failed: %v", err)
+			}
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+
+	// RFC 6455 中的示例
+	if got := wsAcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected accept key: %s", got)
+	}
+}
+
+// TestWebSocket_CheckOrigin 测试默认的同源校验和自定义的 Origin 校验
+func TestWebSocket_CheckOrigin(t *testing.T) {
+	tests := []struct {
+		origin, host string
+		want         bool
+	}{
+		{"", "rpc.example:8080", true},
+		{"http://RPC.example:8080", "rpc.example:8080", true},
+		{"http://rpc.example", "rpc.example:8080", false},
+		{"https://evil.example", "rpc.example:8080", false},
+		{"::", "rpc.example:8080", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		r.Host = tt.host
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := sameOrigin(r); got != tt.want {
+			t.Errorf("sameOrigin(%q, %q) = %v, want %v", tt.origin, tt.host, got, tt.want)
+		}
+	}
+
+	// 自定义校验允许指定的跨站来源
+	server := NewServerWithConfig(ServerConfig{
+		CheckOrigin: func(r *http.Request) bool { return r.Header.Get("Origin") == "https://dashboard.example" },
+	})
+	defer server.Close()
+	for origin, want := range map[string]bool{
+		"https://dashboard.example": true,
+		"https://evil.example":      false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		r.Header.Set("Origin", origin)
+		if got := server.checkOrigin(r); got != want {
+			t.Errorf("checkOrigin(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+// TestWebSocket_Call 测试客户端通过 WebSocket 传输并发调用
+func TestWebSocket_Call(t *testing.T) {
+	_, url := newWebSocketTestServer(t)
+
+	client, err := NewClient(ClientConfig{Address: url})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reply := &AddReply{}
+			if err := client.Call(ctx, "TestService.Add", &AddArgs{A: i, B: i}, reply); err != nil {
+				t.Errorf("Call failed: %v", err)
+				return
+			}
+			if reply.Result != 2*i {
+				t.Errorf("Expected result %d, got %d", 2*i, reply.Result)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 超过 125 字节和 64KB 的消息使用扩展长度
+	for _, size := range []int{200, 70000} {
+		msg := strings.Repeat("x", size)
+		reply := &EchoReply{}
+		if err := client.Call(ctx, "TestService.Echo", &EchoArgs{Message: msg}, reply); err != nil {
+			t.Fatalf("Echo %d bytes failed: %v", size, err)
+		}
+		if reply.Message != msg {
+			t.Errorf("Echo %d bytes: message mismatch", size)
+		}
+	}
+}
+
+// TestWebSocket_ServerPush 测试服务方法通过 ServerConn 向客户端推送通知
+func TestWebSocket_ServerPush(t *testing.T) {
+	_, url := newWebSocketTestServer(t)
+
+	received := make(chan string, 1)
+	client, err := NewClient(ClientConfig{
+		Address: url,
+		OnNotification: func(method string, params json.RawMessage) {
+			var args EchoArgs
+			json.Unmarshal(params, &args)
+			received <- method + ":" + args.Message
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reply := &EchoReply{}
+	if err := client.Call(ctx, "TestService.Subscribe", &EchoArgs{Message: "hello"}, reply); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if reply.Message != "subscribed" {
+		t.Errorf("Expected subscribed, got %s", reply.Message)
+	}
+
+	// 通知先于响应写出，读协程同步调用回调，此时通知应该已经到达
+	select {
+	case got := <-received:
+		if got != "TestService.OnMessage:hello" {
+			t.Errorf("Unexpected notification: %s", got)
+		}
+	default:
+		t.Error("Expected notification before response")
+	}
+}
+
+// TestWebSocket_MaxRequestBytes 测试过大的消息返回错误响应并关闭连接
+func TestWebSocket_MaxRequestBytes(t *testing.T) {
+	server, url := newWebSocketTestServer(t)
+	server.SetMaxRequestBytes(128)
+
+	conn, err := dialWebSocket(url, 5*time.Second, nil, connOptions{})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	large := `{"jsonrpc":"2.0","method":"TestService.Echo","params":{"message":"` + strings.Repeat("x", 256) + `"},"id":1}`
+	if err := conn.WriteMessage([]byte(large), time.Time{}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	var resp Response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Message != ErrMsgRequestTooLarge {
+		t.Fatalf("Expected request too large error, got %s", data)
+	}
+
+	if _, err := conn.ReadMessage(); err == nil {
+		t.Error("Expected connection to be closed")
+	}
+}
+
+// TestWebSocket_ShutdownDuringHandshake 测试 Shutdown 等待正在握手的 WebSocket 连接处理完成
+func TestWebSocket_ShutdownDuringHandshake(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	server := NewServerWithConfig(ServerConfig{
+		CheckOrigin: func(r *http.Request) bool {
+			close(entered)
+			<-release
+			return true
+		},
+	})
+	ts := httptest.NewServer(server.WebSocketHandler())
+	defer ts.Close()
+
+	upgraded := make(chan io.Closer, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("Handshake failed: %v", err)
+			upgraded <- io.NopCloser(nil)
+			return
+		}
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Errorf("Expected status 101, got %d", resp.StatusCode)
+		}
+		upgraded <- resp.Body
+	}()
+	<-entered
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- server.Shutdown(ctx)
+	}()
+
+	select {
+	case err := <-shutdown:
+		close(release)
+		t.Fatalf("Shutdown returned before the handshake finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 握手完成后客户端关闭连接，Shutdown 随之返回
+	close(release)
+	(<-upgraded).Close()
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Errorf("Shutdown failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return")
+	}
+}